@@ -0,0 +1,121 @@
+package halo
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// HaloSpec gives the arguments to New for a single halo in a batch. The zero
+// value of MassProfile is an NFW profile.
+type HaloSpec struct {
+	Cosmology     cosmo.Cosmology
+	FThermal      FThermalModel
+	Pressure      PressureProfile
+	MassProfile   MassProfile
+	Concentration num.Func1D
+	Bias          BiasType
+	M500c, Z      float64
+}
+
+// BatchFunc computes the quantities of interest for a single halo in a
+// batch.
+type BatchFunc func(h *Halo) ([]float64, error)
+
+// BatchResult is the outcome of evaluating a single HaloSpec. If Err is
+// non-nil, it is a *BatchError and Halo and Values may be nil.
+type BatchResult struct {
+	Halo   *Halo
+	Values []float64
+	Err    error
+}
+
+// EvalBatch constructs a Halo for each of specs and calls eval on it, using
+// at most workers goroutines. If workers <= 0, runtime.GOMAXPROCS(0) is used,
+// and if eval is nil the halos are only constructed.
+//
+// The returned results are in the same order as specs. Errors, including
+// panics raised by eval, are recorded in the result of the spec which caused
+// them and do not stop the rest of the batch. If ctx is cancelled, no new
+// halos are started, halos which are being constructed are allowed to
+// finish, and the results of skipped specs are set to ctx.Err(). In that
+// case EvalBatch returns ctx.Err() alongside the partial results.
+func EvalBatch(ctx context.Context, specs []HaloSpec, workers int, eval BatchFunc) ([]BatchResult, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(specs) {
+		workers = len(specs)
+	}
+
+	results := make([]BatchResult, len(specs))
+	var skipped int32
+
+	idx := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = &BatchError{i, err}
+					atomic.AddInt32(&skipped, 1)
+					continue
+				}
+				results[i] = evalSpec(specs[i], eval)
+				if results[i].Err != nil {
+					results[i].Err = &BatchError{i, results[i].Err}
+				}
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for ; sent < len(specs); sent++ {
+		select {
+		case idx <- sent:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(idx)
+	wg.Wait()
+
+	if sent == len(specs) && skipped == 0 {
+		return results, nil
+	}
+	err := ctx.Err()
+	for i := sent; i < len(specs); i++ {
+		results[i].Err = &BatchError{i, err}
+	}
+	return results, err
+}
+
+// evalSpec constructs and evaluates the halo described by spec, converting
+// panics into errors.
+func evalSpec(spec HaloSpec, eval BatchFunc) (res BatchResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			res = BatchResult{Err: fmt.Errorf("halo: panic: %v", p)}
+		}
+	}()
+
+	h, err := New(spec.Cosmology, spec.FThermal, spec.Pressure, spec.MassProfile,
+		spec.Concentration, spec.Bias, spec.M500c, spec.Z)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	if eval == nil {
+		return BatchResult{Halo: h}
+	}
+
+	vals, err := eval(h)
+	return BatchResult{Halo: h, Values: vals, Err: err}
+}
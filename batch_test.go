@@ -0,0 +1,119 @@
+package halo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// batchSpecs returns n HaloSpecs with increasing masses.
+func batchSpecs(t *testing.T, n int) []HaloSpec {
+	c := cosmo.Fiducial
+	cFunc, err := ConcentrationFunc(c, Bhattacharya2013, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fTh, err := FThermalFunc(Battaglia2013, MeanCurve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := make([]HaloSpec, n)
+	for i := range specs {
+		specs[i] = HaloSpec{
+			Cosmology: c, FThermal: fTh, Pressure: BattagliaAGN2012,
+			Concentration: cFunc, Bias: Corrected,
+			M500c: 1e14 * float64(i+1), Z: 0.2,
+		}
+	}
+	return specs
+}
+
+func TestEvalBatch(t *testing.T) {
+	specs := batchSpecs(t, 12)
+	specs[3].FThermal = nil
+	eval := func(h *Halo) ([]float64, error) {
+		if m := specs[5].M500c; math.Abs(h.C500.M/m-1) < 1e-3 {
+			panic("eval panic")
+		}
+		return []float64{h.C500.M, h.M500cBias}, nil
+	}
+
+	results, err := EvalBatch(context.Background(), specs, 4, eval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		var bErr *BatchError
+		switch i {
+		case 3, 5:
+			if !errors.As(res.Err, &bErr) || bErr.Index != i {
+				t.Errorf("result %d: error %v, expected a *BatchError with "+
+					"Index %d", i, res.Err, i)
+			}
+			var nilErr *NilArgumentError
+			if i == 3 && !errors.As(res.Err, &nilErr) {
+				t.Errorf("result 3: error %v does not wrap a "+
+					"*NilArgumentError", res.Err)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Errorf("result %d: %s", i, res.Err)
+			continue
+		}
+
+		spec := specs[i]
+		h, err := New(spec.Cosmology, spec.FThermal, spec.Pressure,
+			spec.MassProfile, spec.Concentration, spec.Bias, spec.M500c, spec.Z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Values[0] != h.C500.M || res.Values[1] != h.M500cBias {
+			t.Errorf("result %d: values %v, expected [%g %g]", i, res.Values,
+				h.C500.M, h.M500cBias)
+		}
+	}
+}
+
+func TestEvalBatchCancel(t *testing.T) {
+	specs := batchSpecs(t, 8)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := EvalBatch(ctx, specs, 2, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("EvalBatch with a cancelled context returned %v", err)
+	}
+	for i, res := range results {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("result %d: error %v, expected context.Canceled", i,
+				res.Err)
+		}
+	}
+
+	// Cancelling from inside the batch skips every later spec.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	eval := func(h *Halo) ([]float64, error) {
+		cancel()
+		return nil, nil
+	}
+	results, err = EvalBatch(ctx, specs, 1, eval)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("EvalBatch cancelled by eval returned %v", err)
+	}
+	if results[0].Err != nil || results[0].Halo == nil {
+		t.Errorf("result 0: halo %p, error %v, expected a halo", results[0].Halo,
+			results[0].Err)
+	}
+	for i := 1; i < len(results); i++ {
+		if !errors.Is(results[i].Err, context.Canceled) {
+			t.Errorf("result %d: error %v, expected context.Canceled", i,
+				results[i].Err)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package halo
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+const (
+	biasEmulatorMagic   = "HBEM"
+	biasEmulatorVersion = 1
+
+	// Largest label, grid, and transfer table sizes accepted when reading.
+	biasEmulatorMaxLabel    = 1 << 16
+	biasEmulatorMaxGrid     = 1 << 28
+	biasEmulatorMaxTransfer = 1 << 24
+)
+
+// BiasEmulatorConfig describes the halos tabulated by a BiasEmulator. Halos
+// are constructed with New on a grid which is uniformly spaced in
+// log10(M500c) and z, with MassBins and ZBins points along each axis. Bias
+// specifies whether the mass axis is the biased or the true M500c of the
+// halos.
+//
+// The FThermal and Pressure models cannot be stored in a file, so they are
+// identified by Label, which must be non-empty and should change whenever
+// either model does. Label, Cosmology, MassProfile, Concentration, and Bias
+// are stored with the emulator and checked by ReadBiasEmulator.
+type BiasEmulatorConfig struct {
+	Label         string
+	Cosmology     cosmo.Cosmology
+	FThermal      FThermalModel
+	Pressure      PressureProfile
+	MassProfile   MassProfile
+	Concentration ConcentrationType
+	Bias          BiasType
+
+	MinM500c, MaxM500c float64
+	MinZ, MaxZ         float64
+	// MassBins and ZBins must be at least 4.
+	MassBins, ZBins int
+
+	// Workers is passed to EvalBatch.
+	Workers int
+}
+
+// BiasEmulation contains the quantities interpolated by a BiasEmulator for a
+// single halo.
+type BiasEmulation struct {
+	// Bias is C500.M / M500cBias.
+	Bias float64
+	// RadiusRatio is C500.R / R500cBias.
+	RadiusRatio float64
+	// BFrac is BFrac(R500cBias).
+	BFrac float64
+}
+
+// BiasEmulatorAccuracy gives the largest relative error of each quantity
+// interpolated by a BiasEmulator when compared to direct evaluation at the
+// center of every cell of its grid.
+type BiasEmulatorAccuracy struct {
+	Points                   int
+	Bias, RadiusRatio, BFrac float64
+}
+
+// BiasEmulator interpolates the mass bias of a family of halos over mass and
+// redshift, so that it can be evaluated without constructing a Halo. Values
+// are found by bicubic interpolation on a grid built by BuildBiasEmulator.
+//
+// A BiasEmulator is immutable and safe for concurrent use.
+type BiasEmulator struct {
+	label         string
+	bias          BiasType
+	cosmology     cosmo.Cosmology
+	massProfile   MassProfile
+	concentration ConcentrationType
+	// Grid axes. logM is log10(M500c).
+	logMMin, logMMax, zMin, zMax float64
+	nm, nz                       int
+	// Tabulated values, indexed as [iz*nm + im].
+	vals     [3][]float64
+	accuracy BiasEmulatorAccuracy
+}
+
+// BuildBiasEmulator constructs the halos described by cfg and tabulates
+// their bias. The accuracy of the resulting BiasEmulator is measured by
+// constructing an additional halo at the center of every grid cell. An
+// error is returned if any halo cannot be constructed or if ctx is
+// cancelled.
+func BuildBiasEmulator(ctx context.Context, cfg BiasEmulatorConfig) (*BiasEmulator, error) {
+	if cfg.Label == "" || len(cfg.Label) > biasEmulatorMaxLabel {
+		return nil, fmt.Errorf("halo: BiasEmulator label must have between "+
+			"1 and %d bytes, has %d", biasEmulatorMaxLabel, len(cfg.Label))
+	} else if cfg.MinM500c <= 0 || !(cfg.MaxM500c > cfg.MinM500c) {
+		return nil, fmt.Errorf("halo: BiasEmulator mass range [%g, %g] is "+
+			"invalid", cfg.MinM500c, cfg.MaxM500c)
+	} else if !(cfg.MaxZ > cfg.MinZ) || cfg.MinZ < 0 {
+		return nil, fmt.Errorf("halo: BiasEmulator redshift range [%g, %g] "+
+			"is invalid", cfg.MinZ, cfg.MaxZ)
+	} else if cfg.MassBins < 4 || cfg.ZBins < 4 {
+		return nil, fmt.Errorf("halo: BiasEmulator needs at least 4 grid "+
+			"points along each axis, got %d x %d", cfg.MassBins, cfg.ZBins)
+	}
+
+	e := &BiasEmulator{
+		label: cfg.Label, bias: cfg.Bias, cosmology: cfg.Cosmology,
+		massProfile: cfg.MassProfile, concentration: cfg.Concentration,
+		logMMin: math.Log10(cfg.MinM500c), logMMax: math.Log10(cfg.MaxM500c),
+		zMin: cfg.MinZ, zMax: cfg.MaxZ,
+		nm: cfg.MassBins, nz: cfg.ZBins,
+	}
+
+	// Grid points, followed by cell centers.
+	logM, z := []float64{}, []float64{}
+	for iz := 0; iz < e.nz; iz++ {
+		for im := 0; im < e.nm; im++ {
+			logM, z = append(logM, e.logM(float64(im))), append(z, e.z(float64(iz)))
+		}
+	}
+	for iz := 0; iz < e.nz-1; iz++ {
+		for im := 0; im < e.nm-1; im++ {
+			logM = append(logM, e.logM(float64(im)+0.5))
+			z = append(z, e.z(float64(iz)+0.5))
+		}
+	}
+
+	specs := make([]HaloSpec, len(logM))
+	for i := range specs {
+		cFunc, err := ConcentrationFunc(cfg.Cosmology, cfg.Concentration, z[i])
+		if err != nil {
+			return nil, err
+		}
+		specs[i] = HaloSpec{
+			Cosmology: cfg.Cosmology,
+			FThermal:  cfg.FThermal, Pressure: cfg.Pressure,
+			MassProfile: cfg.MassProfile, Concentration: cFunc,
+			Bias: cfg.Bias, M500c: math.Pow(10, logM[i]), Z: z[i],
+		}
+	}
+
+	results, err := EvalBatch(ctx, specs, cfg.Workers, evalBiasEmulation)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+	}
+
+	n := e.nm * e.nz
+	for j := range e.vals {
+		e.vals[j] = make([]float64, n)
+		for i := 0; i < n; i++ {
+			e.vals[j][i] = results[i].Values[j]
+		}
+	}
+
+	e.accuracy.Points = len(results) - n
+	errs := []*float64{
+		&e.accuracy.Bias, &e.accuracy.RadiusRatio, &e.accuracy.BFrac,
+	}
+	for i := n; i < len(results); i++ {
+		em := e.Eval(math.Pow(10, logM[i]), z[i])
+		for j, val := range []float64{em.Bias, em.RadiusRatio, em.BFrac} {
+			d := math.Abs(val/results[i].Values[j] - 1)
+			if d > *errs[j] {
+				*errs[j] = d
+			}
+		}
+	}
+
+	return e, nil
+}
+
+func evalBiasEmulation(h *Halo) ([]float64, error) {
+	return []float64{
+		h.C500.M / h.M500cBias, h.C500.R / h.R500cBias, h.BFrac(h.R500cBias),
+	}, nil
+}
+
+// logM returns log10(M500c) at the fractional grid index u.
+func (e *BiasEmulator) logM(u float64) float64 {
+	return e.logMMin + u*(e.logMMax-e.logMMin)/float64(e.nm-1)
+}
+
+// z returns the redshift at the fractional grid index u.
+func (e *BiasEmulator) z(u float64) float64 {
+	return e.zMin + u*(e.zMax-e.zMin)/float64(e.nz-1)
+}
+
+// Label returns the label of the f_th and pressure models of the halos
+// tabulated by e.
+func (e *BiasEmulator) Label() string { return e.label }
+
+// BiasType returns the type of the mass which e is evaluated at.
+func (e *BiasEmulator) BiasType() BiasType { return e.bias }
+
+// Cosmology returns the cosmology of the halos tabulated by e.
+func (e *BiasEmulator) Cosmology() cosmo.Cosmology { return e.cosmology }
+
+// Accuracy returns the accuracy of e measured when it was built.
+func (e *BiasEmulator) Accuracy() BiasEmulatorAccuracy { return e.accuracy }
+
+// Eval returns the interpolated bias quantities of a halo with the given
+// M500c and redshift. M500c is biased if e.BiasType() is Biased and true
+// otherwise. Every field is NaN if the halo lies outside of e's grid.
+func (e *BiasEmulator) Eval(m500c, z float64) BiasEmulation {
+	um := (math.Log10(m500c) - e.logMMin) / (e.logMMax - e.logMMin) *
+		float64(e.nm-1)
+	uz := (z - e.zMin) / (e.zMax - e.zMin) * float64(e.nz-1)
+	if !(um >= 0 && um <= float64(e.nm-1) && uz >= 0 && uz <= float64(e.nz-1)) {
+		nan := math.NaN()
+		return BiasEmulation{nan, nan, nan}
+	}
+
+	sm, wm := cubicStencil(um, e.nm)
+	sz, wz := cubicStencil(uz, e.nz)
+
+	var out [3]float64
+	for j := range out {
+		for a := 0; a < 4; a++ {
+			row := (sz+a)*e.nm + sm
+			for b := 0; b < 4; b++ {
+				out[j] += wz[a] * wm[b] * e.vals[j][row+b]
+			}
+		}
+	}
+	return BiasEmulation{out[0], out[1], out[2]}
+}
+
+// cubicStencil returns the first index and the weights of the four grid
+// points used to interpolate a cubic polynomial at the fractional index u of
+// a grid with n points. The stencil is centered on u away from the edges of
+// the grid.
+func cubicStencil(u float64, n int) (int, [4]float64) {
+	s := int(u) - 1
+	if s < 0 {
+		s = 0
+	} else if s > n-4 {
+		s = n - 4
+	}
+
+	t := u - float64(s)
+	return s, [4]float64{
+		-(t - 1) * (t - 2) * (t - 3) / 6,
+		t * (t - 2) * (t - 3) / 2,
+		-t * (t - 1) * (t - 3) / 2,
+		t * (t - 1) * (t - 2) / 6,
+	}
+}
+
+// biasEmulatorHeader is the fixed-size header of a BiasEmulator file. All
+// values are little-endian. The header is followed by a biasEmulatorModel
+// and its label, a biasEmulatorCosmology and its transfer table, and then
+// by the Bias, RadiusRatio, and BFrac grids, each of which is MassBins*ZBins
+// float64 values indexed as [iz*MassBins + im].
+type biasEmulatorHeader struct {
+	Magic            [4]byte
+	Version          uint32
+	Bias             uint32
+	MassBins, ZBins  uint32
+	LogMMin, LogMMax float64
+	ZMin, ZMax       float64
+	AccuracyPoints   uint32
+	AccuracyBias     float64
+	AccuracyRadius   float64
+	AccuracyBFrac    float64
+}
+
+// biasEmulatorModel identifies the halo models of a BiasEmulator. It is
+// followed by the LabelBytes bytes of the label.
+type biasEmulatorModel struct {
+	Concentration      uint32
+	MassProfile        uint32
+	Alpha, Beta, Gamma float64
+	RtFrac, Be, Se     float64
+	LabelBytes         uint32
+}
+
+// biasEmulatorCosmology is the cosmology of a BiasEmulator. It is followed
+// by the TransferRows wavenumbers and then the TransferRows values of the
+// cosmology's TransferTable, if it has one.
+type biasEmulatorCosmology struct {
+	OmegaM, OmegaL, OmegaB, OmegaR float64
+	W0, Wa                         float64
+	H100, Sigma8, Ns, YHe          float64
+	Sigma                          uint32
+	TransferRows                   uint32
+}
+
+// BiasEmulatorMismatchError is returned by ReadBiasEmulator when a stored
+// BiasEmulator tabulates different halos than the expected configuration.
+// Field names the first field of BiasEmulatorConfig which differs.
+type BiasEmulatorMismatchError struct {
+	Field string
+}
+
+func (e *BiasEmulatorMismatchError) Error() string {
+	return fmt.Sprintf("halo: stored BiasEmulator has a different %s than "+
+		"expected", e.Field)
+}
+
+// Write writes e to wr in a versioned binary format which can be read with
+// ReadBiasEmulator.
+func (e *BiasEmulator) Write(wr io.Writer) error {
+	hd := biasEmulatorHeader{
+		Version: biasEmulatorVersion, Bias: uint32(e.bias),
+		MassBins: uint32(e.nm), ZBins: uint32(e.nz),
+		LogMMin: e.logMMin, LogMMax: e.logMMax, ZMin: e.zMin, ZMax: e.zMax,
+		AccuracyPoints: uint32(e.accuracy.Points),
+		AccuracyBias:   e.accuracy.Bias,
+		AccuracyRadius: e.accuracy.RadiusRatio,
+		AccuracyBFrac:  e.accuracy.BFrac,
+	}
+	copy(hd.Magic[:], biasEmulatorMagic)
+
+	mp := e.massProfile
+	hm := biasEmulatorModel{
+		Concentration: uint32(e.concentration), MassProfile: uint32(mp.Type),
+		Alpha: mp.Alpha, Beta: mp.Beta, Gamma: mp.Gamma,
+		RtFrac: mp.RtFrac, Be: mp.Be, Se: mp.Se,
+		LabelBytes: uint32(len(e.label)),
+	}
+
+	c := e.cosmology
+	hc := biasEmulatorCosmology{
+		OmegaM: c.OmegaM, OmegaL: c.OmegaL, OmegaB: c.OmegaB, OmegaR: c.OmegaR,
+		W0: c.W0, Wa: c.Wa, H100: c.H100, Sigma8: c.Sigma8, Ns: c.Ns,
+		YHe: c.YHe, Sigma: uint32(c.Sigma),
+	}
+	k, t := []float64{}, []float64{}
+	if c.Transfer != nil {
+		k, t = c.Transfer.Table()
+		hc.TransferRows = uint32(len(k))
+	}
+
+	blocks := []interface{}{
+		&hd, &hm, []byte(e.label), &hc, k, t, e.vals[0], e.vals[1], e.vals[2],
+	}
+	for _, block := range blocks {
+		if err := binary.Write(wr, binary.LittleEndian, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes e to the named file. See Write.
+func (e *BiasEmulator) WriteFile(fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	if err = e.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadBiasEmulator reads a BiasEmulator written by BiasEmulator.Write. The
+// Label, Cosmology, MassProfile, Concentration, and Bias of cfg must match
+// those the emulator was built with, or a *BiasEmulatorMismatchError is
+// returned. The remaining fields of cfg are not used. An error is also
+// returned if the data was written with an unsupported version of the
+// format.
+func ReadBiasEmulator(rd io.Reader, cfg BiasEmulatorConfig) (*BiasEmulator, error) {
+	hd := biasEmulatorHeader{}
+	if err := binary.Read(rd, binary.LittleEndian, &hd); err != nil {
+		return nil, err
+	}
+
+	if string(hd.Magic[:]) != biasEmulatorMagic {
+		return nil, fmt.Errorf("halo: data is not a BiasEmulator")
+	} else if hd.Version != biasEmulatorVersion {
+		return nil, fmt.Errorf("halo: BiasEmulator has format version %d, "+
+			"but only version %d is supported", hd.Version,
+			biasEmulatorVersion)
+	} else if hd.MassBins < 4 || hd.ZBins < 4 ||
+		uint64(hd.MassBins)*uint64(hd.ZBins) > biasEmulatorMaxGrid {
+		return nil, fmt.Errorf("halo: BiasEmulator has invalid grid size "+
+			"%d x %d", hd.MassBins, hd.ZBins)
+	} else if BiasType(hd.Bias) != Biased && BiasType(hd.Bias) != Corrected {
+		return nil, &EnumError{Type: "BiasType", Value: int(hd.Bias)}
+	}
+
+	hm := biasEmulatorModel{}
+	if err := binary.Read(rd, binary.LittleEndian, &hm); err != nil {
+		return nil, err
+	} else if hm.LabelBytes > biasEmulatorMaxLabel {
+		return nil, fmt.Errorf("halo: BiasEmulator has invalid label size "+
+			"%d", hm.LabelBytes)
+	}
+	label := make([]byte, hm.LabelBytes)
+	if _, err := io.ReadFull(rd, label); err != nil {
+		return nil, err
+	}
+
+	hc := biasEmulatorCosmology{}
+	if err := binary.Read(rd, binary.LittleEndian, &hc); err != nil {
+		return nil, err
+	} else if hc.TransferRows > biasEmulatorMaxTransfer {
+		return nil, fmt.Errorf("halo: BiasEmulator has invalid transfer "+
+			"table size %d", hc.TransferRows)
+	}
+	c := cosmo.Cosmology{
+		OmegaM: hc.OmegaM, OmegaL: hc.OmegaL, OmegaB: hc.OmegaB,
+		OmegaR: hc.OmegaR, W0: hc.W0, Wa: hc.Wa, H100: hc.H100,
+		Sigma8: hc.Sigma8, Ns: hc.Ns, YHe: hc.YHe,
+		Sigma: cosmo.SigmaType(hc.Sigma),
+	}
+	if hc.TransferRows > 0 {
+		k := make([]float64, hc.TransferRows)
+		t := make([]float64, hc.TransferRows)
+		if err := binary.Read(rd, binary.LittleEndian, k); err != nil {
+			return nil, err
+		} else if err = binary.Read(rd, binary.LittleEndian, t); err != nil {
+			return nil, err
+		}
+		tt, err := cosmo.NewTransferTable(k, t)
+		if err != nil {
+			return nil, err
+		}
+		c.Transfer = tt
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	e := &BiasEmulator{
+		label: string(label), bias: BiasType(hd.Bias), cosmology: c,
+		massProfile: MassProfile{
+			MassProfileType(hm.MassProfile), hm.Alpha, hm.Beta, hm.Gamma,
+			hm.RtFrac, hm.Be, hm.Se,
+		},
+		concentration: ConcentrationType(hm.Concentration),
+		logMMin:       hd.LogMMin, logMMax: hd.LogMMax,
+		zMin: hd.ZMin, zMax: hd.ZMax,
+		nm: int(hd.MassBins), nz: int(hd.ZBins),
+		accuracy: BiasEmulatorAccuracy{
+			int(hd.AccuracyPoints),
+			hd.AccuracyBias, hd.AccuracyRadius, hd.AccuracyBFrac,
+		},
+	}
+	if err := e.checkConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	for j := range e.vals {
+		e.vals[j] = make([]float64, e.nm*e.nz)
+		if err := binary.Read(rd, binary.LittleEndian, e.vals[j]); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// checkConfig returns a *BiasEmulatorMismatchError if e was not built for
+// the halos described by cfg.
+func (e *BiasEmulator) checkConfig(cfg BiasEmulatorConfig) error {
+	switch {
+	case e.label != cfg.Label:
+		return &BiasEmulatorMismatchError{"Label"}
+	case !sameCosmology(e.cosmology, cfg.Cosmology):
+		return &BiasEmulatorMismatchError{"Cosmology"}
+	case e.massProfile != cfg.MassProfile:
+		return &BiasEmulatorMismatchError{"MassProfile"}
+	case e.concentration != cfg.Concentration:
+		return &BiasEmulatorMismatchError{"Concentration"}
+	case e.bias != cfg.Bias:
+		return &BiasEmulatorMismatchError{"Bias"}
+	}
+	return nil
+}
+
+// sameCosmology returns true if a and b are the same cosmology. Transfer
+// tables are compared by value, up to the rounding of a round trip through
+// a file.
+func sameCosmology(a, b cosmo.Cosmology) bool {
+	ta, tb := a.Transfer, b.Transfer
+	a.Transfer, b.Transfer = nil, nil
+	if a != b || (ta == nil) != (tb == nil) {
+		return false
+	} else if ta == nil || ta == tb {
+		return true
+	}
+
+	ka, va := ta.Table()
+	kb, vb := tb.Table()
+	if len(ka) != len(kb) {
+		return false
+	}
+	for i := range ka {
+		if math.Abs(ka[i]/kb[i]-1) > 1e-12 || math.Abs(va[i]/vb[i]-1) > 1e-12 {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadBiasEmulatorFile reads a BiasEmulator from the named file. See
+// ReadBiasEmulator.
+func ReadBiasEmulatorFile(fname string, cfg BiasEmulatorConfig) (*BiasEmulator, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBiasEmulator(f, cfg)
+}
@@ -0,0 +1,120 @@
+package halo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+func emulatorConfig(t *testing.T) BiasEmulatorConfig {
+	fTh, err := FThermalFunc(Battaglia2013, MeanCurve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cosmo.Planck2018
+	c.W0, c.Wa = -0.9, 0.1
+	return BiasEmulatorConfig{
+		Label:     "Battaglia2013 mean, BattagliaAGN2012",
+		Cosmology: c, FThermal: fTh, Pressure: BattagliaAGN2012,
+		MassProfile: MassProfile{Type: Einasto}, Concentration: Duffy2011,
+		Bias:     Biased,
+		MinM500c: 3e13, MaxM500c: 1e15, MinZ: 0, MaxZ: 1,
+		MassBins: 4, ZBins: 4,
+	}
+}
+
+func TestBiasEmulatorRoundTrip(t *testing.T) {
+	cfg := emulatorConfig(t)
+	e, err := BuildBiasEmulator(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := e.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// The grid fields of the expected configuration are not checked.
+	readCfg := cfg
+	readCfg.MassBins, readCfg.MaxZ = 10, 2
+	e2, err := ReadBiasEmulator(bytes.NewReader(data), readCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e2.Label() != cfg.Label || e2.Cosmology() != cfg.Cosmology ||
+		e2.BiasType() != cfg.Bias || e2.Accuracy() != e.Accuracy() {
+		t.Errorf("read emulator has label %q, cosmology %+v, BiasType %d, "+
+			"and accuracy %+v", e2.Label(), e2.Cosmology(), e2.BiasType(),
+			e2.Accuracy())
+	}
+	for _, m := range []float64{3e13, 1e14, 7e14} {
+		for _, z := range []float64{0, 0.3, 1} {
+			if got, want := e2.Eval(m, z), e.Eval(m, z); got != want {
+				t.Errorf("read emulator gives %+v at (%g, %g), expected %+v",
+					got, m, z, want)
+			}
+		}
+	}
+
+	mismatches := map[string]func(*BiasEmulatorConfig){
+		"Label":         func(c *BiasEmulatorConfig) { c.Label = "other" },
+		"Cosmology":     func(c *BiasEmulatorConfig) { c.Cosmology.Wa = 0 },
+		"MassProfile":   func(c *BiasEmulatorConfig) { c.MassProfile.Alpha = 0.2 },
+		"Concentration": func(c *BiasEmulatorConfig) { c.Concentration = Prada2011 },
+		"Bias":          func(c *BiasEmulatorConfig) { c.Bias = Corrected },
+	}
+	for field, change := range mismatches {
+		other := cfg
+		change(&other)
+		_, err := ReadBiasEmulator(bytes.NewReader(data), other)
+		var mErr *BiasEmulatorMismatchError
+		if !errors.As(err, &mErr) || mErr.Field != field {
+			t.Errorf("different %s gave error %v", field, err)
+		}
+	}
+
+	data[4] = biasEmulatorVersion + 1
+	if _, err := ReadBiasEmulator(bytes.NewReader(data), cfg); err == nil {
+		t.Errorf("unsupported format version did not cause an error")
+	}
+
+	cfg.Label = ""
+	if _, err := BuildBiasEmulator(context.Background(), cfg); err == nil {
+		t.Errorf("empty label did not cause an error")
+	}
+}
+
+func TestSameCosmologyTransfer(t *testing.T) {
+	k := []float64{1e-3, 1e-2, 0.1, 1, 10}
+	tk := []float64{1, 0.98, 0.6, 0.05, 0.001}
+	tt, err := cosmo.NewTransferTable(k, tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A copy of tt read back from its own table, as ReadBiasEmulator does.
+	tt2, err := cosmo.NewTransferTable(tt.Table())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk[2] = 0.61
+	tt3, err := cosmo.NewTransferTable(k, tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := cosmo.Planck2018
+	a.Sigma, a.Transfer = cosmo.TabulatedTransfer, tt
+	b, c, d := a, a, a
+	b.Transfer, c.Transfer, d.Transfer = tt2, tt3, nil
+
+	if !sameCosmology(a, b) {
+		t.Errorf("cosmologies with equal transfer tables differ")
+	}
+	if sameCosmology(a, c) || sameCosmology(a, d) {
+		t.Errorf("cosmologies with different transfer tables are the same")
+	}
+}
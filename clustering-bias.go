@@ -0,0 +1,102 @@
+package halo
+
+import (
+	"math"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// ClusteringBiasType specifies a fit to the large-scale linear bias of halos,
+// the ratio between the clustering amplitude of halos of a given mass and
+// that of the matter. Each fit is a function of the peak height,
+// nu = DeltaCollapse / sigma(M, z), given by the cosmology's PeakHeightFunc.
+//
+// This is unrelated to the hydrostatic mass bias described by BiasType and
+// BFrac.
+//
+// MoWhite1996 and ShethMoTormen2001 do not depend on the overdensity
+// definition. Tinker2010 supports overdensities between 200 and 3200 times
+// the average density.
+type ClusteringBiasType uint32
+
+const (
+	MoWhite1996 ClusteringBiasType = iota
+	ShethMoTormen2001
+	Tinker2010
+
+	clusteringBiasTypeCount
+)
+
+const (
+	smtA = 0.707
+	smtB = 0.5
+	smtC = 0.6
+
+	tinkerBiasB  = 0.183
+	tinkerBiasb  = 1.5
+	tinkerBiasc  = 2.4
+	tinkerBiasC0 = 0.019
+)
+
+// clusteringBiasNuFunc returns the bias of the given fit as a function of
+// peak height for halos with the overdensity definition d at redshift z.
+func clusteringBiasNuFunc(cosmology cosmo.Cosmology, cbType ClusteringBiasType, d DensityType, z float64) (num.Func1D, error) {
+	dc := cosmo.DeltaCollapse
+
+	switch cbType {
+	case MoWhite1996:
+		return func(nu float64) float64 {
+			return 1 + (nu*nu-1)/dc
+		}, nil
+
+	case ShethMoTormen2001:
+		sqrtA := math.Sqrt(smtA)
+		return func(nu float64) float64 {
+			anu2 := smtA * nu * nu
+			anu2c := math.Pow(anu2, smtC)
+			return 1 + (sqrtA*anu2+sqrtA*smtB*math.Pow(anu2, 1-smtC)-
+				anu2c/(anu2c+smtB*(1-smtC)*(1-smtC/2)))/(sqrtA*dc)
+		}, nil
+
+	case Tinker2010:
+		delta, err := tinkerDeltaM("Tinker2010", cosmology, d, z)
+		if err != nil {
+			return nil, err
+		}
+
+		y := math.Log10(delta)
+		cutoff := math.Exp(-math.Pow(4/y, 4))
+		A := 1 + 0.24*y*cutoff
+		a := 0.44*y - 0.88
+		C := tinkerBiasC0 + 0.107*y + 0.19*cutoff
+		dca := math.Pow(dc, a)
+
+		return func(nu float64) float64 {
+			nua := math.Pow(nu, a)
+			return 1 - A*nua/(nua+dca) + tinkerBiasB*math.Pow(nu, tinkerBiasb) +
+				C*math.Pow(nu, tinkerBiasc)
+		}, nil
+	}
+
+	return nil, &EnumError{Type: "ClusteringBiasType", Value: int(cbType)}
+}
+
+// ClusteringBiasFunc returns a function which gives the large-scale linear
+// bias of halos with mass M, in M_sun, under the overdensity definition d at
+// redshift z in the given cosmology. Peak heights are computed with
+// cosmology.Sigma.
+func ClusteringBiasFunc(cosmology cosmo.Cosmology, cbType ClusteringBiasType, d DensityType, z float64) (num.Func1D, error) {
+	if err := cosmology.Validate(); err != nil {
+		return nil, err
+	} else if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := clusteringBiasNuFunc(cosmology, cbType, d, z)
+	if err != nil {
+		return nil, err
+	}
+	nu := cosmology.PeakHeightFunc(cosmology.Sigma, z)
+	return func(m float64) float64 { return b(nu(m)) }, nil
+}
@@ -0,0 +1,79 @@
+package halo
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// TestMoWhiteNonlinearMass checks that halos with the nonlinear mass, for
+// which nu = 1, have the same clustering as the matter.
+func TestMoWhiteNonlinearMass(t *testing.T) {
+	c := cosmo.Fiducial
+	for _, z := range []float64{0, 1} {
+		nu := c.PeakHeightFunc(c.Sigma, z)
+		mStar, _, err := findEqualConst("M_*", nu, 1, 1e13)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ClusteringBiasFunc(c, MoWhite1996, A200, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bias := b(mStar); math.Abs(bias-1) > 1e-8 {
+			t.Errorf("z = %g: b(M_* = %.5g) = %.10g, expected 1", z, mStar,
+				bias)
+		}
+		if b(10*mStar) <= 1 || b(mStar/10) >= 1 {
+			t.Errorf("z = %g: b(10 M_*) = %g, b(M_* / 10) = %g, expected "+
+				"them to lie on either side of 1", z, b(10*mStar), b(mStar/10))
+		}
+	}
+}
+
+// TestMoWhitePeakBackgroundSplit checks that the Mo & White bias, which is
+// derived from the Press-Schechter mass function, satisfies
+// int b(nu) f(nu) dln(nu) = 1, so that the matter is unbiased with respect
+// to itself.
+func TestMoWhitePeakBackgroundSplit(t *testing.T) {
+	c := cosmo.Fiducial
+	b, err := clusteringBiasNuFunc(c, MoWhite1996, fof, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := multiplicityFunc(c, PressSchechter1974, fof, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	integral := simpson(func(lnNu float64) float64 {
+		nu := math.Exp(lnNu)
+		return b(nu) * f(cosmo.DeltaCollapse/nu)
+	}, -25, 4, 4000)
+	if math.Abs(integral-1) > 1e-8 {
+		t.Errorf("int b f dln(nu) = %.10g, expected 1", integral)
+	}
+}
+
+func TestClusteringBiasErrors(t *testing.T) {
+	c := cosmo.Fiducial
+	_, err := ClusteringBiasFunc(c, Tinker2010, fof, 0)
+	var pErr *ParameterError
+	if !errors.As(err, &pErr) {
+		t.Errorf("Tinker2010 with 180m gave error %v, expected a "+
+			"*ParameterError", err)
+	}
+	if _, err := ClusteringBiasFunc(c, Tinker2010, A200, 0); err != nil {
+		t.Errorf("Tinker2010 with 200m: %s", err)
+	}
+
+	_, err = ClusteringBiasFunc(c, clusteringBiasTypeCount, A200, 0)
+	var eErr *EnumError
+	if !errors.As(err, &eErr) {
+		t.Errorf("invalid ClusteringBiasType gave error %v, expected an "+
+			"*EnumError", err)
+	}
+}
@@ -59,29 +59,29 @@ func bhattacharyaNu(d, m200cH float64) float64 {
 	return (1.12 * math.Pow(m200cH/bhattacharyaPivotMassH, 0.3) + 0.53) / d
 }
 
-func pradaX(a float64) float64 {
-	return a * math.Pow(cosmo.OmegaL/cosmo.OmegaM, 1.0/3.0)
+func pradaX(c cosmo.Cosmology, a float64) float64 {
+	return a * math.Pow(c.OmegaL/c.OmegaM, 1.0/3.0)
 }
 
 // ConcentrationFunc returns a function which transforms a 200c mass into
 // a concentration via the specified paper's fit for all halos (not just
-// relaxed ones) at the specified redshift.
+// relaxed ones) at the specified redshift in the given cosmology.
 //
 // A halo's concentration, c is equal to r_s / r_200c, where r_s is a
 // parameter in the halo's NFW density profile specifying the distance at
 // which the slope is -2 on a logarithmic scale.
-func ConcentrationFunc(cType ConcentrationType, z float64) num.Func1D {
+func ConcentrationFunc(cosmology cosmo.Cosmology, cType ConcentrationType, z float64) (num.Func1D, error) {
 	switch cType {
 	case Duffy2011:
 		firstTerm := duffyA * math.Pow(1+z, duffyC)
 		return func(m200c float64) float64 {
-			m200cH := m200c * cosmo.H100
+			m200cH := m200c * cosmology.H100
 			return firstTerm * math.Pow(m200cH/duffyPivotMassH, duffyB)
-		}
+		}, nil
 
 	case Prada2011:
-		x := pradaX(1.0 / (1.0 + z))
-		sigma := cosmo.SigmaFunc(cosmo.MultiDark2010, z)
+		x := pradaX(cosmology, 1.0/(1.0+z))
+		sigma := cosmology.SigmaFunc(cosmology.Sigma, z)
 		cMin := minFunc(pradaC0, pradaC1, pradaAlpha, pradaX0)
 		sigmaMin := minFunc(pradaSigma0, pradaSigma1, pradaBeta, pradaX1)
 
@@ -92,15 +92,21 @@ func ConcentrationFunc(cType ConcentrationType, z float64) num.Func1D {
 			C := (pradaA * (math.Pow(sigmaP/pradaB, pradaC) + 1.0) *
 				math.Exp(pradaD/(sigmaP*sigmaP)))
 			return B0 * C
-		}
+		}, nil
 
 	case Bhattacharya2013:
-		d := cosmo.DFluctuation(1.0 / (1.0 + z))
-		return func(m200c float64) float64 {
-			nu := bhattacharyaNu(d, m200c*cosmo.H100)
-			return (math.Pow(d, 0.54) * 5.9 * math.Pow(nu, -0.35))
+		d := cosmology.GrowthFactor(1.0 / (1.0 + z))
+		nu := func(m200c float64) float64 {
+			return bhattacharyaNu(d, m200c*cosmology.H100)
 		}
+		if cosmology.Sigma != cosmo.MultiDark2010 {
+			// Use the true peak height rather than the fit to it.
+			nu = cosmology.PeakHeightFunc(cosmology.Sigma, z)
+		}
+		return func(m200c float64) float64 {
+			return (math.Pow(d, 0.54) * 5.9 * math.Pow(nu(m200c), -0.35))
+		}, nil
 	}
 
-	panic("Unrecognized ConcentrationType")
+	return nil, &EnumError{Type: "ConcentrationType", Value: int(cType)}
 }
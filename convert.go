@@ -0,0 +1,152 @@
+package halo
+
+import (
+	"fmt"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// ConversionError is returned by the mass conversion functions. It records
+// the arguments of the failed conversion and wraps the error which caused it
+// to fail. Index is the position of M within the input slice of the
+// vectorized conversion functions and is zero otherwise.
+type ConversionError struct {
+	From, To DensityType
+	M, Z     float64
+	Index    int
+	Err      error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("halo: could not convert M%v = %.5g (index %d) to "+
+		"M%v at z = %.5g: %v", e.From, e.M, e.Index, e.To, e.Z, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error { return e.Err }
+
+// newProfileHalo creates a Halo which only has a true density profile. None
+// of its pressure or bias information is initialized.
+func newProfileHalo(cosmology cosmo.Cosmology, mp MassProfile, from, to DensityType, m, z float64) (*Halo, error) {
+	if m < MinHaloMass || m > MaxHaloMass {
+		return nil, &MassBoundsError{m, MinHaloMass, MaxHaloMass}
+	}
+	if err := from.Validate(); err != nil {
+		return nil, err
+	} else if err = to.Validate(); err != nil {
+		return nil, err
+	} else if err = cosmology.Validate(); err != nil {
+		return nil, err
+	}
+
+	h := &Halo{Z: z, cosmology: cosmology}
+	if err := initProfile(h, mp, m); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// convert finds the DensityInfo of a halo with true mass m under the
+// definition from, given a concentration relation for it.
+func convert(cosmology cosmo.Cosmology, mp MassProfile, cFunc num.Func1D, from, to DensityType, m, z float64) (DensityInfo, error) {
+	h, err := newProfileHalo(cosmology, mp, from, to, m, z)
+	if err != nil {
+		return DensityInfo{}, err
+	}
+	return convertProfileHalo(h, cFunc, from, to, m)
+}
+
+func convertProfileHalo(h *Halo, cFunc num.Func1D, from, to DensityType, m float64) (DensityInfo, error) {
+	r := haloRadius(m, from.Density(h.cosmology, h.Z))
+	if err := initSearching(h, cFunc, m, r); err != nil {
+		return DensityInfo{}, err
+	}
+
+	rho := to.Density(h.cosmology, h.Z)
+	rTo, err := h.overdensityRadius(Corrected, rho)
+	if err != nil {
+		return DensityInfo{}, err
+	}
+	return DensityInfo{haloMass(rTo, rho), rTo, rTo / h.Rs}, nil
+}
+
+// ConvertMass converts the true mass m of a halo at redshift z from the
+// overdensity definition from to the overdensity definition to in the given
+// cosmology. The halo is assumed to have the profile mp and the
+// concentration relation cFunc, which maps m200c to concentration. No
+// pressure or bias calculations are performed.
+func ConvertMass(cosmology cosmo.Cosmology, mp MassProfile, cFunc num.Func1D, from, to DensityType, m, z float64) (float64, error) {
+	info, err := convert(cosmology, mp, cFunc, from, to, m, z)
+	if err != nil {
+		return 0, &ConversionError{from, to, m, z, 0, err}
+	}
+	return info.M, nil
+}
+
+// ConvertMasses converts each of the masses in ms in the same manner as
+// ConvertMass and returns the converted masses in the same order. The
+// first failed conversion is returned as an error.
+func ConvertMasses(cosmology cosmo.Cosmology, mp MassProfile, cFunc num.Func1D, from, to DensityType, ms []float64, z float64) ([]float64, error) {
+	out := make([]float64, len(ms))
+	for i, m := range ms {
+		info, err := convert(cosmology, mp, cFunc, from, to, m, z)
+		if err != nil {
+			return nil, &ConversionError{from, to, m, z, i, err}
+		}
+		out[i] = info.M
+	}
+	return out, nil
+}
+
+// ConvertMassConcentration converts the true mass m and concentration c of
+// a halo at redshift z from the overdensity definition from to the
+// overdensity definition to in the given cosmology. The halo is assumed to
+// have the profile mp. Concentrations are defined as in MassProfile.
+func ConvertMassConcentration(cosmology cosmo.Cosmology, mp MassProfile, from, to DensityType, m, c, z float64) (mTo, cTo float64, err error) {
+	info, err := convertConcentrationHalo(cosmology, mp, from, to, m, c, z)
+	if err != nil {
+		return 0, 0, &ConversionError{from, to, m, z, 0, err}
+	}
+	return info.M, info.C, nil
+}
+
+// ConvertMassesConcentrations converts each of the masses in ms and the
+// corresponding concentrations in cs in the same manner as
+// ConvertMassConcentration. A *ParameterError is returned if ms and cs
+// have different lengths.
+func ConvertMassesConcentrations(cosmology cosmo.Cosmology, mp MassProfile, from, to DensityType, ms, cs []float64, z float64) (msTo, csTo []float64, err error) {
+	if len(ms) != len(cs) {
+		return nil, nil, &ParameterError{
+			"ConvertMassesConcentrations", "len(cs)", float64(len(cs)),
+			fmt.Sprintf("len(cs) = len(ms) = %d", len(ms)),
+		}
+	}
+
+	msTo, csTo = make([]float64, len(ms)), make([]float64, len(ms))
+	for i := range ms {
+		info, err := convertConcentrationHalo(cosmology, mp, from, to, ms[i],
+			cs[i], z)
+		if err != nil {
+			return nil, nil, &ConversionError{from, to, ms[i], z, i, err}
+		}
+		msTo[i], csTo[i] = info.M, info.C
+	}
+	return msTo, csTo, nil
+}
+
+func convertConcentrationHalo(cosmology cosmo.Cosmology, mp MassProfile, from, to DensityType, m, c, z float64) (DensityInfo, error) {
+	h, err := newProfileHalo(cosmology, mp, from, to, m, z)
+	if err != nil {
+		return DensityInfo{}, err
+	}
+	if c <= 0 {
+		return DensityInfo{}, &ParameterError{
+			"ConvertMassConcentration", "c", c, "c > 0",
+		}
+	}
+	cFunc, err := fixedConcentrationFunc(h, from, c)
+	if err != nil {
+		return DensityInfo{}, err
+	}
+	return convertProfileHalo(h, cFunc, from, to, m)
+}
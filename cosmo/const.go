@@ -7,6 +7,7 @@ const (
 	OmegaR         = 0.0
 
 	Sigma8 = 0.82
+	Ns     = 0.96
 
 	H100 = 0.7
 	H70  = H100 / 0.7
@@ -21,9 +22,13 @@ const (
 	MpcMks  = 3.08560e+22
 	MSunMks = 1.98900e+30
 	CMks    = 2.99792e+08
+	GyrMks  = 3.15576e+16
 
 	SigmaTMks = 6.65246e-29
 
+	// CMB temperature today in K.
+	TCMB = 2.7255
+
 	XHy = 0.76
 	YHe = 1.0 - XHy
 
@@ -0,0 +1,215 @@
+package cosmo
+
+import (
+	"fmt"
+	"math"
+)
+
+// validateMinA is the smallest scale factor at which Validate checks the
+// expansion rate.
+const validateMinA = 1e-6
+
+// Cosmology contains the parameters of a cosmology. YHe is the primordial
+// helium mass fraction and Ns is the spectral index of the primordial power
+// spectrum.
+//
+// OmegaL is the density of dark energy, which has the equation of state
+// w(a) = W0 + Wa (1 - a) (Chevallier & Polarski, 2001; Linder, 2003). A
+// cosmological constant has W0 = -1 and Wa = 0. W0 must be set explicitly:
+// Validate rejects the zero value so that a forgotten W0 is not mistaken
+// for w = 0. The curvature density is whatever is needed to close the
+// Friedmann equation, so the cosmology is flat if
+// OmegaM + OmegaL + OmegaR = 1. See OmegaK.
+//
+// Sigma selects the relation used for sigma(M) wherever one is needed, such
+// as in concentration relations, and Transfer is the transfer function used
+// by the TabulatedTransfer SigmaType.
+//
+// Cosmology is a plain value, so any number of cosmologies may be used at
+// once.
+type Cosmology struct {
+	OmegaM, OmegaL, OmegaB, OmegaR float64
+	W0, Wa                         float64
+
+	H100   float64
+	Sigma8 float64
+	Ns     float64
+
+	YHe float64
+
+	Sigma    SigmaType
+	Transfer *TransferTable
+}
+
+var (
+	// Fiducial is the cosmology described by the constants in const.go. It
+	// is used by the package-level functions.
+	Fiducial = Cosmology{
+		OmegaM: OmegaM, OmegaL: OmegaL, OmegaB: OmegaB, OmegaR: OmegaR,
+		W0: -1,
+		H100: H100, Sigma8: Sigma8, Ns: Ns, YHe: YHe,
+	}
+
+	// WMAP7 is the WMAP+BAO+H0 cosmology of Komatsu et al. (2011).
+	WMAP7 = Cosmology{
+		OmegaM: 0.275, OmegaL: 0.725, OmegaB: 0.0458,
+		W0: -1,
+		H100: 0.702, Sigma8: 0.816, Ns: 0.968, YHe: 0.24,
+	}
+
+	// WMAP9 is the WMAP+eCMB+BAO+H0 cosmology of Hinshaw et al. (2013).
+	WMAP9 = Cosmology{
+		OmegaM: 0.2865, OmegaL: 0.7135, OmegaB: 0.04628,
+		W0: -1,
+		H100: 0.6932, Sigma8: 0.820, Ns: 0.9608, YHe: 0.24,
+	}
+
+	// Planck2013 is the Planck+WP+highL+BAO cosmology of Planck
+	// Collaboration XVI (2014).
+	Planck2013 = Cosmology{
+		OmegaM: 0.308, OmegaL: 0.692, OmegaB: 0.04816,
+		W0: -1,
+		H100: 0.678, Sigma8: 0.826, Ns: 0.9608, YHe: 0.2477,
+	}
+
+	// Planck2015 is the TT,TE,EE+lowP+lensing+ext cosmology of Planck
+	// Collaboration XIII (2016).
+	Planck2015 = Cosmology{
+		OmegaM: 0.3089, OmegaL: 0.6911, OmegaB: 0.04860,
+		W0: -1,
+		H100: 0.6774, Sigma8: 0.8159, Ns: 0.9667, YHe: 0.2453,
+	}
+
+	// Planck2018 is the TT,TE,EE+lowE+lensing+BAO cosmology of Planck
+	// Collaboration VI (2020).
+	Planck2018 = Cosmology{
+		OmegaM: 0.3111, OmegaL: 0.6889, OmegaB: 0.04897,
+		W0: -1,
+		H100: 0.6766, Sigma8: 0.8102, Ns: 0.9665, YHe: 0.2454,
+	}
+)
+
+// Validate returns an error if c does not describe a physical cosmology.
+func (c Cosmology) Validate() error {
+	switch {
+	case !(c.H100 > 0):
+		return fmt.Errorf("cosmo: H100 = %g, must be positive", c.H100)
+	case !(c.OmegaM > 0):
+		return fmt.Errorf("cosmo: OmegaM = %g, must be positive", c.OmegaM)
+	case !(c.OmegaL >= 0):
+		return fmt.Errorf("cosmo: OmegaL = %g, must be non-negative", c.OmegaL)
+	case c.W0 == 0:
+		return fmt.Errorf("cosmo: W0 = 0; W0 must be set explicitly, e.g. " +
+			"to -1 for a cosmological constant")
+	case !(c.OmegaR >= 0):
+		return fmt.Errorf("cosmo: OmegaR = %g, must be non-negative", c.OmegaR)
+	case !(c.OmegaB >= 0 && c.OmegaB <= c.OmegaM):
+		return fmt.Errorf("cosmo: OmegaB = %g, must be within [0, OmegaM]",
+			c.OmegaB)
+	case !(c.Sigma8 > 0):
+		return fmt.Errorf("cosmo: Sigma8 = %g, must be positive", c.Sigma8)
+	case !(c.YHe >= 0 && c.YHe < 1):
+		return fmt.Errorf("cosmo: YHe = %g, must be within [0, 1)", c.YHe)
+	case c.Sigma >= sigmaTypeCount:
+		return fmt.Errorf("cosmo: Sigma = %d is not a valid SigmaType",
+			c.Sigma)
+	case (c.Sigma == EisensteinHu1998 || c.Sigma == EisensteinHu1998NoBAO) &&
+		!(c.OmegaB > 0):
+		return fmt.Errorf("cosmo: Eisenstein & Hu transfer functions " +
+			"require OmegaB > 0")
+	case c.Sigma == TabulatedTransfer && c.Transfer == nil:
+		return fmt.Errorf("cosmo: TabulatedTransfer requires a Transfer " +
+			"table")
+	}
+
+	// Closed universes with enough dark energy never reach a = 0.
+	for a := 1.0; a >= validateMinA; a /= 2 {
+		if e2 := c.hubbleFrac2(a); !(e2 > 0) {
+			return fmt.Errorf("cosmo: H(z)^2 = %g at z = %g, must be "+
+				"positive", e2, 1/a-1)
+		}
+	}
+	return nil
+}
+
+// OmegaK returns the curvature density of c, 1 - OmegaM - OmegaL - OmegaR.
+// It is positive for open universes and negative for closed ones.
+func (c Cosmology) OmegaK() float64 {
+	return 1 - c.OmegaM - c.OmegaL - c.OmegaR
+}
+
+// W returns the dark energy equation of state parameter at a = 1 / (1 + z).
+func (c Cosmology) W(a float64) float64 {
+	return c.W0 + c.Wa*(1-a)
+}
+
+// darkEnergy returns the density of dark energy at a relative to its
+// density today.
+func (c Cosmology) darkEnergy(a float64) float64 {
+	if c.W0 == -1 && c.Wa == 0 {
+		return 1
+	}
+	return math.Pow(a, -3*(1+c.W0+c.Wa)) * math.Exp(-3*c.Wa*(1-a))
+}
+
+// dLnDarkEnergy returns d ln(rho_DE) / d ln(a).
+func (c Cosmology) dLnDarkEnergy(a float64) float64 {
+	return -3 * (1 + c.W(a))
+}
+
+// hubbleFrac2 returns h(a)^2 = (H(a)/H0)^2.
+func (c Cosmology) hubbleFrac2(a float64) float64 {
+	return c.hubbleFrac2z(1/a - 1)
+}
+
+func (c Cosmology) hubbleFrac2z(z float64) float64 {
+	a := 1 / (1 + z)
+	return c.OmegaR*math.Pow(1.0+z, 4.0) + c.OmegaM*math.Pow(1.0+z, 3.0) +
+		c.OmegaK()*(1+z)*(1+z) + c.OmegaL*c.darkEnergy(a)
+}
+
+// H70 returns H0 in units of 70 km/s/Mpc.
+func (c Cosmology) H70() float64 { return c.H100 / 0.7 }
+
+// XHy returns the primordial hydrogen mass fraction.
+func (c Cosmology) XHy() float64 { return 1 - c.YHe }
+
+// Mu returns the number of particles per hydrogen mass in fully ionized
+// primordial gas, i.e. the inverse of the mean particle mass in units of the
+// hydrogen mass, 1 / mu.
+func (c Cosmology) Mu() float64 { return 2.0*c.XHy() + 3.0/4.0*c.YHe }
+
+// ElectronMu returns the number of electrons per hydrogen mass in fully
+// ionized primordial gas, i.e. the inverse of the mass per electron in units
+// of the hydrogen mass, 1 / mu_e.
+func (c Cosmology) ElectronMu() float64 { return c.XHy() + 2.0/4.0*c.YHe }
+
+// HubbleFrac calculates h(z) = H(z)/H0 in c, including the contributions
+// of curvature and evolving dark energy.
+func (c Cosmology) HubbleFrac(z float64) float64 {
+	return math.Sqrt(c.hubbleFrac2z(z))
+}
+
+func (c Cosmology) rhoCriticalMks(z float64) float64 {
+	H := c.HubbleFrac(z) * H0Mks * c.H100
+	return 3.0 * H * H / (8.0 * math.Pi * GMks)
+}
+
+// RhoCritical calculates the critical density of the universe in c. The
+// returned value is in cosmological units.
+func (c Cosmology) RhoCritical(z float64) float64 {
+	return c.rhoCriticalMks(z) * MpcMks * MpcMks * MpcMks / MSunMks
+}
+
+// RhoAverage calculates the average density of matter in the universe in c.
+// The returned value is in cosmological units. Matter dilutes as (1 + z)^3,
+// so this is OmegaM times the critical density today, not at z.
+func (c Cosmology) RhoAverage(z float64) float64 {
+	return c.RhoCritical(0) * c.OmegaM * math.Pow(1+z, 3.0)
+}
+
+// RhoBaryonAverage calculates the average density of baryons in the
+// universe in c. The returned value is in cosmological units.
+func (c Cosmology) RhoBaryonAverage(z float64) float64 {
+	return c.RhoCritical(0) * c.OmegaB * math.Pow(1+z, 3.0)
+}
@@ -0,0 +1,83 @@
+package cosmo
+
+import (
+	"math"
+)
+
+// All distances are in Mpc and all times are in Gyr. Integrals over the
+// expansion history are computed numerically from HubbleFrac.
+
+// HubbleDistance returns c / H0 in c.
+func (c Cosmology) HubbleDistance() float64 {
+	return CMks / (H0Mks * c.H100) / MpcMks
+}
+
+// HubbleTime returns 1 / H0 in c.
+func (c Cosmology) HubbleTime() float64 {
+	return 1 / (H0Mks * c.H100) / GyrMks
+}
+
+// ComovingDistance returns the line-of-sight comoving distance to an object
+// at redshift z.
+func (c Cosmology) ComovingDistance(z float64) float64 {
+	return c.HubbleDistance() * integrate(func(zp float64) float64 {
+		return 1 / c.HubbleFrac(zp)
+	}, 0, z)
+}
+
+// TransverseComovingDistance returns the comoving distance between two
+// objects at redshift z which are separated by an angle of one radian. In a
+// flat cosmology this is the same as the line-of-sight comoving distance.
+func (c Cosmology) TransverseComovingDistance(z float64) float64 {
+	dc, omegaK := c.ComovingDistance(z), c.OmegaK()
+	if omegaK == 0 {
+		return dc
+	}
+
+	dh := c.HubbleDistance()
+	sk := math.Sqrt(math.Abs(omegaK))
+	if omegaK > 0 {
+		return dh / sk * math.Sinh(sk*dc/dh)
+	}
+	return dh / sk * math.Sin(sk*dc/dh)
+}
+
+// AngularDiameterDistance returns the ratio of an object's proper size to
+// the angle it subtends for an object at redshift z. An object with radius
+// r at z subtends an angle of r / D_A radians, and a spherical Compton-y
+// parameter with units of Mpc^2, like Halo.ThompsonY, corresponds to an
+// observed Y of Y / D_A^2 steradians.
+func (c Cosmology) AngularDiameterDistance(z float64) float64 {
+	return c.TransverseComovingDistance(z) / (1 + z)
+}
+
+// LuminosityDistance returns the distance which relates the bolometric
+// luminosity of an object at redshift z to its observed flux.
+func (c Cosmology) LuminosityDistance(z float64) float64 {
+	return c.TransverseComovingDistance(z) * (1 + z)
+}
+
+// LookbackTime returns the difference between the age of the universe now
+// and at redshift z.
+func (c Cosmology) LookbackTime(z float64) float64 {
+	return c.HubbleTime() * integrate(func(zp float64) float64 {
+		return 1 / ((1 + zp) * c.HubbleFrac(zp))
+	}, 0, z)
+}
+
+// Age returns the age of the universe at redshift z.
+func (c Cosmology) Age(z float64) float64 {
+	// t = int_0^a da / (a H(a)). Substituting a = u^2 removes the
+	// singularity in the integrand's derivative at a = 0.
+	return c.HubbleTime() * integrate(func(u float64) float64 {
+		a := u * u
+		return 2 / (u * c.HubbleFrac(1/a-1))
+	}, 0, math.Sqrt(1/(1+z)))
+}
+
+// ComovingVolumeElement returns dV / dz / dOmega, the comoving volume per
+// unit redshift per steradian at redshift z, in Mpc^3.
+func (c Cosmology) ComovingVolumeElement(z float64) float64 {
+	dm := c.TransverseComovingDistance(z)
+	return c.HubbleDistance() * dm * dm / c.HubbleFrac(z)
+}
@@ -0,0 +1,79 @@
+package cosmo
+
+import (
+	"math"
+	"testing"
+)
+
+// Largest allowed relative difference between the numerically integrated
+// distances and times and their closed forms.
+const distanceTolerance = 1e-9
+
+func checkDistance(t *testing.T, name string, z, got, want float64) {
+	if math.Abs(got/want-1) > distanceTolerance {
+		t.Errorf("%s at z = %g: %.12g, expected %.12g", name, z, got, want)
+	}
+}
+
+// TestDistanceEdS compares distances and times in an Einstein-de Sitter
+// universe against D_C = 2 D_H (1 - 1 / sqrt(1 + z)) and
+// t = (2/3) t_H (1 + z)^(-3/2).
+func TestDistanceEdS(t *testing.T) {
+	c := Fiducial
+	c.OmegaM, c.OmegaL, c.OmegaR = 1, 0, 0
+	dh, th := c.HubbleDistance(), c.HubbleTime()
+
+	for _, z := range []float64{0.1, 0.5, 1, 3, 10} {
+		dc := 2 * dh * (1 - 1/math.Sqrt(1+z))
+		checkDistance(t, "ComovingDistance", z, c.ComovingDistance(z), dc)
+		checkDistance(t, "AngularDiameterDistance", z,
+			c.AngularDiameterDistance(z), dc/(1+z))
+		checkDistance(t, "LuminosityDistance", z,
+			c.LuminosityDistance(z), dc*(1+z))
+		checkDistance(t, "ComovingVolumeElement", z,
+			c.ComovingVolumeElement(z), dh*dc*dc/math.Pow(1+z, 1.5))
+
+		age := 2 * th / 3 * math.Pow(1+z, -1.5)
+		checkDistance(t, "Age", z, c.Age(z), age)
+		checkDistance(t, "LookbackTime", z, c.LookbackTime(z), 2*th/3-age)
+	}
+}
+
+// TestAgeFlatLCDM compares Age against
+// t = 2 t_H / (3 sqrt(OmegaL)) asinh(sqrt(OmegaL / OmegaM) (1 + z)^(-3/2)),
+// which holds for flat universes containing matter and a cosmological
+// constant.
+func TestAgeFlatLCDM(t *testing.T) {
+	c := Fiducial
+	c.OmegaR = 0
+	c.OmegaL = 1 - c.OmegaM
+	th := c.HubbleTime()
+
+	age := func(z float64) float64 {
+		return 2 * th / (3 * math.Sqrt(c.OmegaL)) *
+			math.Asinh(math.Sqrt(c.OmegaL/c.OmegaM)*math.Pow(1+z, -1.5))
+	}
+	for _, z := range []float64{0, 0.5, 2, 8} {
+		checkDistance(t, "Age", z, c.Age(z), age(z))
+		if z > 0 {
+			checkDistance(t, "LookbackTime", z, c.LookbackTime(z),
+				age(0)-age(z))
+		}
+	}
+}
+
+// TestDistanceOpen compares the luminosity distance of an open,
+// matter-only universe against the formula of Mattig (1958),
+// D_L = 2 D_H (OmegaM z + (OmegaM - 2)(sqrt(1 + OmegaM z) - 1)) / OmegaM^2.
+func TestDistanceOpen(t *testing.T) {
+	c := Fiducial
+	c.OmegaM, c.OmegaL, c.OmegaR = 0.3, 0, 0
+	dh, om := c.HubbleDistance(), c.OmegaM
+
+	for _, z := range []float64{0.1, 0.5, 1, 3, 10} {
+		dl := 2 * dh * (om*z + (om-2)*(math.Sqrt(1+om*z)-1)) / (om * om)
+		checkDistance(t, "LuminosityDistance", z, c.LuminosityDistance(z), dl)
+		checkDistance(t, "AngularDiameterDistance", z,
+			c.AngularDiameterDistance(z), dl/((1+z)*(1+z)))
+	}
+}
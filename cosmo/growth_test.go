@@ -0,0 +1,77 @@
+package cosmo
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	// Number of Simpson steps used by heathGrowth.
+	heathSteps = 2000
+	// Largest allowed relative difference between DFluctuation and
+	// heathGrowth. The largest differences are about 8e-10 for flat
+	// cosmologies and 1.2e-9 for open ones.
+	growthTolerance = 2e-9
+)
+
+// heathGrowth returns the linear growth factor of Heath (1977),
+//
+//     D(a) = (5 OmegaM / 2) E(a) int_0^a da' / (a' E(a'))^3,
+//
+// which is exact for universes containing only matter, curvature, and a
+// cosmological constant. It is normalized like DFluctuation. The integral
+// is taken over t = sqrt(a'), which makes the integrand smooth at a' = 0.
+func heathGrowth(c Cosmology, a float64) float64 {
+	integrand := func(t float64) float64 {
+		ap := t * t
+		if ap == 0 {
+			return 0
+		}
+		ae := ap * c.HubbleFrac(1/ap-1)
+		return 2 * t / (ae * ae * ae)
+	}
+
+	hi := math.Sqrt(a)
+	h := hi / heathSteps
+	sum := integrand(0) + integrand(hi)
+	for i := 1; i < heathSteps; i++ {
+		if i%2 == 1 {
+			sum += 4 * integrand(h*float64(i))
+		} else {
+			sum += 2 * integrand(h*float64(i))
+		}
+	}
+
+	return 2.5 * c.OmegaM * c.HubbleFrac(1/a-1) * sum * h / 3
+}
+
+func TestGrowthHeath(t *testing.T) {
+	open := Fiducial
+	open.OmegaL = 0
+	closed := Fiducial
+	closed.OmegaL = 0.8
+
+	cosmologies := []struct {
+		name string
+		c    Cosmology
+	}{
+		{"Fiducial", Fiducial}, {"Planck2018", Planck2018},
+		{"open", open}, {"closed", closed},
+	}
+
+	for _, cc := range cosmologies {
+		for _, a := range []float64{0.01, 0.03, 0.1, 0.25, 0.5, 0.75, 1} {
+			want := heathGrowth(cc.c, a)
+			if d := cc.c.DFluctuation(a); math.Abs(d/want-1) > growthTolerance {
+				t.Errorf("%s: DFluctuation(%g) = %.12g, Heath integral "+
+					"gives %.12g", cc.name, a, d, want)
+			}
+
+			want /= heathGrowth(cc.c, 1)
+			if g := cc.c.GrowthFactor(a); math.Abs(g/want-1) > growthTolerance {
+				t.Errorf("%s: GrowthFactor(%g) = %.12g, Heath integral "+
+					"gives %.12g", cc.name, a, g, want)
+			}
+		}
+	}
+}
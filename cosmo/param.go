@@ -1,39 +1,23 @@
 package cosmo
 
-import (
-	"math"
-)
-
 // HubbleFrac calculates h(z) = H(z)/H0. Here H(z) is from Hubble's Law,
 // H(z)**2 + k (c/a)**2 = H0**2 h100**2 (OmegaR a**-4 + OmegaM a**-3 + OmegaL).
 // The hubble's constant in const.go is H0 = H(z = 0). An alternate
-// formulation is h(a) = da/dt / (a H0). Assumes k = 0.
-func HubbleFrac(z float64) float64 {
-	return math.Sqrt(OmegaR*math.Pow(1.0+z, 4.0) +
-		OmegaM*math.Pow(1.0+z, 3.0) + OmegaL)
-}
-
-func rhoCriticalMks(z float64) float64 {
-	H := HubbleFrac(z) * H0Mks * H100
-	return 3.0 * H * H / (8.0 * math.Pi * GMks)
-}
+// formulation is h(a) = da/dt / (a H0). Uses the Fiducial cosmology, which
+// is flat.
+func HubbleFrac(z float64) float64 { return Fiducial.HubbleFrac(z) }
 
 // RhoCritical calculates the critical density of the universe. This shows
 // up (among other places) in halo definitions and in the definitions of
 // the omages (OmegaFoo = pFoo / pCritical).  The returned value is in
-// comsological units.
-func RhoCritical(z float64) float64 {
-	return rhoCriticalMks(z) * MpcMks * MpcMks * MpcMks / MSunMks
-}
+// comsological units. Assumes the Fiducial cosmology.
+func RhoCritical(z float64) float64 { return Fiducial.RhoCritical(z) }
 
 // RhoAverage calculates the average density of matter in the universe. The
-// returned value is in cosmological units.
-func RhoAverage(z float64) float64 {
-	return RhoCritical(z) * OmegaM * math.Pow(1+z, 3.0)
-}
+// returned value is in cosmological units. Assumes the Fiducial cosmology.
+func RhoAverage(z float64) float64 { return Fiducial.RhoAverage(z) }
 
 // RhoBaryonAverage calculates the average density of baryons in the
-// universe. The returned values is in cosmological units.
-func RhoBaryonAverage(z float64) float64 {
-	return RhoCritical(z) * OmegaB * math.Pow(1+z, 3.0)
-}
+// universe. The returned values is in cosmological units. Assumes the
+// Fiducial cosmology.
+func RhoBaryonAverage(z float64) float64 { return Fiducial.RhoBaryonAverage(z) }
@@ -0,0 +1,191 @@
+package cosmo
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+const (
+	// sigma(M) is tabulated between sigmaTableMinM and sigmaTableMaxM, in
+	// M_sun, with sigmaTablePerDecade points per decade. Masses outside
+	// this range are integrated directly.
+	sigmaTableMinM      = 1e3
+	sigmaTableMaxM      = 1e18
+	sigmaTablePerDecade = 10
+	// At most sigmaTableCacheSize sigma(M) tables are cached at once.
+	sigmaTableCacheSize = 64
+
+	// The range of k R integrated over when computing sigma(R) and the
+	// relative accuracy of the integral.
+	sigmaMinKR     = 1e-4
+	sigmaMaxKR     = 1e3
+	sigmaTolerance = 1e-8
+)
+
+// sigmaTable contains ln(sigma) at z = 0 on a grid uniformly spaced in
+// ln(M) and the amplitude of the primordial power spectrum which normalizes
+// it to Sigma8.
+type sigmaTable struct {
+	key        Cosmology
+	once       sync.Once
+	ampl       float64
+	lnM0, dlnM float64
+	lnSigma    []float64
+}
+
+var (
+	// sigmaTables caches the sigma(M) tables of the most recently used
+	// cosmologies, keyed by the cosmology with its Sigma set to the type of
+	// the table. sigmaTableLRU holds the *sigmaTable of each entry, most
+	// recently used first; the least recently used table is evicted once
+	// there are more than sigmaTableCacheSize of them.
+	sigmaTablesMtx sync.Mutex
+	sigmaTables    = map[Cosmology]*list.Element{}
+	sigmaTableLRU  = list.New()
+)
+
+// usesPowerSpectrum returns true if sigma(M) of the given type is computed
+// from a linear power spectrum.
+func (sType SigmaType) usesPowerSpectrum() bool {
+	return sType == EisensteinHu1998 || sType == EisensteinHu1998NoBAO ||
+		sType == TabulatedTransfer
+}
+
+// transfer returns the transfer function of the given type with k in 1/Mpc.
+func (c Cosmology) transfer(sType SigmaType) num.Func1D {
+	switch sType {
+	case EisensteinHu1998:
+		p := c.ehParams()
+		return p.transferBAO
+	case EisensteinHu1998NoBAO:
+		p := c.ehParams()
+		return p.transferNoBAO
+	case TabulatedTransfer:
+		if c.Transfer == nil {
+			panic("TabulatedTransfer SigmaType used without a TransferTable.")
+		}
+		return func(k float64) float64 { return c.Transfer.eval(k / c.H100) }
+	}
+	panic("Given unrecognized SigmaType")
+}
+
+// topHat returns the Fourier transform of a spherical top-hat window at
+// x = k R.
+func topHat(x float64) float64 {
+	if x < 1e-3 {
+		return 1 - x*x/10
+	}
+	return 3 * (math.Sin(x) - x*math.Cos(x)) / (x * x * x)
+}
+
+// sigma2 returns the variance of the linear density field in spheres of
+// radius r, in Mpc, for a power spectrum of ampl k^Ns T(k)^2.
+func (c Cosmology) sigma2(t num.Func1D, ampl, r float64) float64 {
+	integral := integrateTol(func(lnK float64) float64 {
+		k := math.Exp(lnK)
+		tk, w := t(k), topHat(k*r)
+		return k * k * k * math.Pow(k, c.Ns) * tk * tk * w * w
+	}, math.Log(sigmaMinKR/r), math.Log(sigmaMaxKR/r), sigmaTolerance)
+	return ampl * integral / (2 * math.Pi * math.Pi)
+}
+
+// lagrangianRadius returns the comoving radius, in Mpc, which encloses the
+// mass m at the mean matter density.
+func (c Cosmology) lagrangianRadius(m float64) float64 {
+	return math.Cbrt(3 * m / (4 * math.Pi * c.RhoAverage(0)))
+}
+
+// cachedSigmaTable returns the cached table for key, adding an empty table
+// to the cache if there is none. Tables which have been evicted from the
+// cache remain valid for callers which still hold them.
+func cachedSigmaTable(key Cosmology) *sigmaTable {
+	sigmaTablesMtx.Lock()
+	defer sigmaTablesMtx.Unlock()
+
+	if elem, ok := sigmaTables[key]; ok {
+		sigmaTableLRU.MoveToFront(elem)
+		return elem.Value.(*sigmaTable)
+	}
+
+	tab := &sigmaTable{key: key}
+	sigmaTables[key] = sigmaTableLRU.PushFront(tab)
+	if sigmaTableLRU.Len() > sigmaTableCacheSize {
+		oldest := sigmaTableLRU.Remove(sigmaTableLRU.Back())
+		delete(sigmaTables, oldest.(*sigmaTable).key)
+	}
+	return tab
+}
+
+// sigmaTable returns the cached sigma(M) table of the given type, building
+// it if needed.
+func (c Cosmology) sigmaTable(sType SigmaType) *sigmaTable {
+	key := c
+	key.Sigma = sType
+	tab := cachedSigmaTable(key)
+
+	tab.once.Do(func() {
+		t := c.transfer(sType)
+		tab.ampl = c.Sigma8 * c.Sigma8 / c.sigma2(t, 1, 8/c.H100)
+
+		n := int(math.Round(math.Log10(sigmaTableMaxM/sigmaTableMinM)*
+			sigmaTablePerDecade)) + 1
+		tab.lnM0 = math.Log(sigmaTableMinM)
+		tab.dlnM = math.Log(10) / sigmaTablePerDecade
+		tab.lnSigma = make([]float64, n)
+		for i := range tab.lnSigma {
+			m := math.Exp(tab.lnM0 + tab.dlnM*float64(i))
+			s2 := c.sigma2(t, tab.ampl, c.lagrangianRadius(m))
+			tab.lnSigma[i] = math.Log(s2) / 2
+		}
+	})
+	return tab
+}
+
+// sigmaM returns sigma(M) at z = 0, interpolating the table with a cubic
+// polynomial in ln(M).
+func (c Cosmology) sigmaM(sType SigmaType, tab *sigmaTable, m float64) float64 {
+	n := len(tab.lnSigma)
+	u := (math.Log(m) - tab.lnM0) / tab.dlnM
+	if !(u >= 0 && u <= float64(n-1)) {
+		r := c.lagrangianRadius(m)
+		return math.Sqrt(c.sigma2(c.transfer(sType), tab.ampl, r))
+	}
+
+	s := int(u) - 1
+	if s < 0 {
+		s = 0
+	} else if s > n-4 {
+		s = n - 4
+	}
+	t := u - float64(s)
+	y := tab.lnSigma[s : s+4]
+	lnSigma := -(t-1)*(t-2)*(t-3)/6*y[0] + t*(t-2)*(t-3)/2*y[1] -
+		t*(t-1)*(t-3)/2*y[2] + t*(t-1)*(t-2)/6*y[3]
+	return math.Exp(lnSigma)
+}
+
+// PowerSpectrum returns the linear matter power spectrum P(k) at redshift z
+// in c, normalized so that sigma(8 Mpc/h) = c.Sigma8 at z = 0. k is in 1/Mpc
+// and P(k) is in Mpc^3. An error is returned if sType is not computed from a
+// power spectrum.
+func (c Cosmology) PowerSpectrum(sType SigmaType, z float64) (num.Func1D, error) {
+	if !sType.usesPowerSpectrum() {
+		return nil, fmt.Errorf("cosmo: SigmaType %d has no power spectrum",
+			sType)
+	} else if sType == TabulatedTransfer && c.Transfer == nil {
+		return nil, fmt.Errorf("cosmo: TabulatedTransfer SigmaType used " +
+			"without a TransferTable")
+	}
+
+	t := c.transfer(sType)
+	d := c.GrowthFactor(1 / (1 + z))
+	ampl := c.sigmaTable(sType).ampl * d * d
+	return func(k float64) float64 {
+		tk := t(k)
+		return ampl * math.Pow(k, c.Ns) * tk * tk
+	}, nil
+}
@@ -0,0 +1,39 @@
+package cosmo
+
+import (
+	"testing"
+)
+
+func TestSigmaTableCache(t *testing.T) {
+	sigma := func(c Cosmology) float64 {
+		return c.SigmaFunc(EisensteinHu1998, 0)(1e14)
+	}
+	c := Fiducial
+	c.Sigma = EisensteinHu1998
+	want := sigma(c)
+	first := cachedSigmaTable(c)
+
+	for i := 0; i < 2*sigmaTableCacheSize; i++ {
+		ci := c
+		ci.Sigma8 = 0.7 + 0.001*float64(i)
+		cachedSigmaTable(ci)
+
+		sigmaTablesMtx.Lock()
+		n, nLRU := len(sigmaTables), sigmaTableLRU.Len()
+		sigmaTablesMtx.Unlock()
+		if n > sigmaTableCacheSize || n != nLRU {
+			t.Fatalf("%d cosmologies used: %d cached tables and %d LRU "+
+				"entries, expected at most %d", i+2, n, nLRU,
+				sigmaTableCacheSize)
+		}
+	}
+
+	// c's table has been evicted and must be rebuilt identically.
+	if cachedSigmaTable(c) == first {
+		t.Errorf("least recently used table was not evicted")
+	}
+	if got := sigma(c); got != want {
+		t.Errorf("sigma(1e14) = %.12g after eviction, expected %.12g",
+			got, want)
+	}
+}
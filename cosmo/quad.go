@@ -0,0 +1,92 @@
+package cosmo
+
+import (
+	"math"
+)
+
+const (
+	// Default relative accuracy targeted by integrate.
+	quadTolerance = 1e-10
+	// Maximum number of times an interval is bisected by integrate.
+	quadMaxDepth = 40
+)
+
+// Nodes and weights of the 7-point Gauss and 15-point Kronrod rules on
+// [-1, 1]. Only the non-negative nodes are listed. The Gauss nodes are the
+// odd-indexed Kronrod nodes.
+var (
+	kronrodNodes = [8]float64{
+		0.991455371120812639206854697526329,
+		0.949107912342758524526189684047851,
+		0.864864423359769072789712788640926,
+		0.741531185599394439863864773280788,
+		0.586087235467691130294144845693013,
+		0.405845151377397166906606412076961,
+		0.207784955007898467600689403773245,
+		0.000000000000000000000000000000000,
+	}
+	kronrodWeights = [8]float64{
+		0.022935322010529224963732008058970,
+		0.063092092629978553290700663189204,
+		0.104790010322250183839876322541518,
+		0.140653259715525918745189590510238,
+		0.169004726639267902826583426598550,
+		0.190350578064785409913256402421014,
+		0.204432940075298892414161999234649,
+		0.209482141084727828012999174891714,
+	}
+	gaussWeights = [4]float64{
+		0.129484966168869693270611432679082,
+		0.279705391489276667901467771423780,
+		0.381830050505118944950369775488975,
+		0.417959183673469387755102040816327,
+	}
+)
+
+// integrate returns the integral of f from a to b, computed with adaptive
+// Gauss-Kronrod quadrature. f is never evaluated at the endpoints, so
+// integrable singularities there are allowed.
+func integrate(f func(float64) float64, a, b float64) float64 {
+	return integrateTol(f, a, b, quadTolerance)
+}
+
+// integrateTol is integrate with a relative accuracy of tol.
+func integrateTol(f func(float64) float64, a, b, tol float64) float64 {
+	if a == b {
+		return 0
+	}
+	k, g := gaussKronrod(f, a, b)
+	// Subintervals are accepted once their error is small compared to the
+	// whole integral, so that negligible tails are not refined.
+	return integrateAdaptive(f, a, b, k, g, tol, tol*math.Abs(k), quadMaxDepth)
+}
+
+func integrateAdaptive(f func(float64) float64, a, b, k, g, tol, absTol float64, depth int) float64 {
+	if err := math.Abs(k - g); err <= tol*math.Abs(k) || err <= absTol || depth == 0 {
+		return k
+	}
+	mid := (a + b) / 2
+	kLo, gLo := gaussKronrod(f, a, mid)
+	kHi, gHi := gaussKronrod(f, mid, b)
+	return integrateAdaptive(f, a, mid, kLo, gLo, tol, absTol, depth-1) +
+		integrateAdaptive(f, mid, b, kHi, gHi, tol, absTol, depth-1)
+}
+
+// gaussKronrod returns the 15-point Kronrod and 7-point Gauss estimates of
+// the integral of f from a to b.
+func gaussKronrod(f func(float64) float64, a, b float64) (k, g float64) {
+	center, half := (a+b)/2, (b-a)/2
+
+	fc := f(center)
+	k = kronrodWeights[7] * fc
+	g = gaussWeights[3] * fc
+	for i := 0; i < 7; i++ {
+		dx := half * kronrodNodes[i]
+		sum := f(center-dx) + f(center+dx)
+		k += kronrodWeights[i] * sum
+		if i%2 == 1 {
+			g += gaussWeights[i/2] * sum
+		}
+	}
+	return k * half, g * half
+}
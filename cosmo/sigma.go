@@ -6,14 +6,31 @@ import (
 	"bitbucket.org/phil-mansfield/halo/num"
 )
 
+// SigmaType specifies how sigma(M), the rms linear density fluctuation in
+// spheres enclosing a mass M, is computed.
+//
+// MultiDark2010 is the fitting function of Prada et al. (2012), which was
+// calibrated against a single WMAP-like cosmology. The remaining types
+// integrate a linear power spectrum, P(k) ~ k^Ns T(k)^2, against a top-hat
+// window and normalize it to Sigma8: EisensteinHu1998 and
+// EisensteinHu1998NoBAO use the Eisenstein & Hu (1998) transfer functions
+// with and without baryon acoustic oscillations, and TabulatedTransfer uses
+// the cosmology's TransferTable.
 type SigmaType uint32
 
 const (
 	MultiDark2010 SigmaType = iota
+	EisensteinHu1998
+	EisensteinHu1998NoBAO
+	TabulatedTransfer
 
 	sigmaTypeCount
 )
 
+// DeltaCollapse is the linear overdensity at which a spherical perturbation
+// collapses.
+const DeltaCollapse = 1.686
+
 const (
 	mdA     = 16.9
 	mdB     = 1.102
@@ -25,41 +42,50 @@ const (
 	mdPivotMassH = 1e12
 )
 
-func pradaX(a float64) float64 {
-	return a * math.Pow(OmegaL/OmegaM, 1.0/3.0)
-}
-
-// DFluctuaction gives the linear growth rate of density fluctuations at
-// a = 1 / (1 + z).
-func DFluctuation(a float64) float64 {
-	x := pradaX(a)
+// DFluctuation gives the linear growth rate of density fluctuations at
+// a = 1 / (1 + z) in the Fiducial cosmology.
+func DFluctuation(a float64) float64 { return Fiducial.DFluctuation(a) }
 
-	term1 := (5.0 / 2.0) * math.Pow(OmegaM/OmegaL, 1.0/3.0)
-	term2 := math.Sqrt((1.0 + x*x*x) / (x * x * x))
-
-	dimlessIntegral := num.Integral(func(xp float64) float64 {
-		return xp / math.Pow(1.0+xp*xp*xp, 1.5)
-	}, 0, x, num.Linear, num.Flat)
-
-	return term1 * term2 * dimlessIntegral(x)
+// SigmaFunc returns a function which transforms a 200c mass into the
+// cosmology-independant mass-proxy, sigma, in the Fiducial cosmology.
+func SigmaFunc(sType SigmaType, z float64) num.Func1D {
+	return Fiducial.SigmaFunc(sType, z)
 }
 
 // SigmaFunc returns a function which transforms a 200c mass into the
-// cosmology-independant mass-proxy, sigma.
-func SigmaFunc(sType SigmaType, z float64) num.Func1D {
+// cosmology-independant mass-proxy, sigma, in c. For SigmaTypes which use a
+// power spectrum, the mass is the mass enclosed by a sphere at the mean
+// matter density and sigma(M) at z = 0 is tabulated the first time each
+// cosmology is used.
+func (c Cosmology) SigmaFunc(sType SigmaType, z float64) num.Func1D {
 	switch sType {
 	case MultiDark2010:
 		a := 1.0 / (1.0 + z)
-		D := DFluctuation(a)
+		D := c.GrowthFactor(a)
 		return func(m200c float64) float64 {
-			m200cH := m200c * H100
+			m200cH := m200c * c.H100
 			y := mdPivotMassH / m200cH
 
 			return (D * mdA * math.Pow(y, mdAlpha) /
 				(1 + mdB*math.Pow(y, mdBeta) +
 					mdC*math.Pow(y, mdGamma)))
 		}
+
+	case EisensteinHu1998, EisensteinHu1998NoBAO, TabulatedTransfer:
+		tab := c.sigmaTable(sType)
+		d := c.GrowthFactor(1.0 / (1.0 + z))
+		return func(m float64) float64 {
+			return d * c.sigmaM(sType, tab, m)
+		}
 	}
 
 	panic("Given unrecognized SigmaType")
 }
+
+// PeakHeightFunc returns a function which gives the peak height,
+// nu = DeltaCollapse / sigma(M, z), of halos with mass M at redshift z in c.
+// Masses are interpreted in the same way as by SigmaFunc.
+func (c Cosmology) PeakHeightFunc(sType SigmaType, z float64) num.Func1D {
+	sigma := c.SigmaFunc(sType, z)
+	return func(m float64) float64 { return DeltaCollapse / sigma(m) }
+}
@@ -0,0 +1,234 @@
+package cosmo
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TransferTotalColumn is the zero-indexed column containing the total
+// matter transfer function in the transfer files written by CAMB and by
+// CLASS with format = camb.
+const TransferTotalColumn = 6
+
+// TransferTable is a tabulated matter transfer function, such as one
+// computed by CAMB or CLASS. It is used by the TabulatedTransfer SigmaType.
+// A TransferTable is immutable and safe for concurrent use.
+type TransferTable struct {
+	// ln(k) with k in h/Mpc, and ln(T) with T normalized to one at the
+	// smallest tabulated k.
+	lnK, lnT []float64
+}
+
+// NewTransferTable creates a TransferTable from the wavenumbers k, in h/Mpc,
+// and the corresponding values of the transfer function. Only the shape of
+// t matters, so it may be given in any units, including the T(k)/k^2 units
+// used by CAMB. k must be strictly increasing and t must not change sign.
+func NewTransferTable(k, t []float64) (*TransferTable, error) {
+	if len(k) != len(t) {
+		return nil, fmt.Errorf("cosmo: transfer table has %d wavenumbers "+
+			"but %d values", len(k), len(t))
+	} else if len(k) < 2 {
+		return nil, fmt.Errorf("cosmo: transfer table has %d rows, needs "+
+			"at least 2", len(k))
+	}
+
+	tt := &TransferTable{
+		lnK: make([]float64, len(k)), lnT: make([]float64, len(t)),
+	}
+	for i := range k {
+		if !(k[i] > 0) || (i > 0 && !(k[i] > k[i-1])) {
+			return nil, fmt.Errorf("cosmo: transfer table wavenumber "+
+				"k[%d] = %g is not positive and increasing", i, k[i])
+		} else if !(t[i]/t[0] > 0) || math.IsInf(t[i]/t[0], 0) {
+			return nil, fmt.Errorf("cosmo: transfer table value t[%d] = "+
+				"%g is invalid", i, t[i])
+		}
+		tt.lnK[i], tt.lnT[i] = math.Log(k[i]), math.Log(t[i]/t[0])
+	}
+	return tt, nil
+}
+
+// ReadTransferTable reads a whitespace-separated text file whose first
+// column is k in h/Mpc, and whose column col contains the transfer function.
+// Blank lines and lines starting with '#' are skipped. For the files written
+// by CAMB and CLASS, col should usually be TransferTotalColumn.
+func ReadTransferTable(fname string, col int) (*TransferTable, error) {
+	if col < 1 {
+		return nil, fmt.Errorf("cosmo: transfer function column %d is "+
+			"invalid", col)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	k, t := []float64{}, []float64{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if col >= len(fields) {
+			return nil, fmt.Errorf("cosmo: line %d of %s has no column %d",
+				line, fname, col)
+		}
+		kVal, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("cosmo: line %d of %s: %s", line, fname,
+				err.Error())
+		}
+		tVal, err := strconv.ParseFloat(fields[col], 64)
+		if err != nil {
+			return nil, fmt.Errorf("cosmo: line %d of %s: %s", line, fname,
+				err.Error())
+		}
+		k, t = append(k, kVal), append(t, tVal)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewTransferTable(k, t)
+}
+
+// Table returns copies of the wavenumbers of tt, in h/Mpc, and of the
+// transfer function, normalized to one at the smallest wavenumber.
+func (tt *TransferTable) Table() (k, t []float64) {
+	k, t = make([]float64, len(tt.lnK)), make([]float64, len(tt.lnT))
+	for i := range k {
+		k[i], t[i] = math.Exp(tt.lnK[i]), math.Exp(tt.lnT[i])
+	}
+	return k, t
+}
+
+// eval returns the transfer function at k, in h/Mpc. It is interpolated
+// linearly in log-log space and extrapolated as a power law from the
+// outermost rows of the table.
+func (tt *TransferTable) eval(k float64) float64 {
+	lnK, n := math.Log(k), len(tt.lnK)
+
+	i := sort.SearchFloat64s(tt.lnK, lnK)
+	if i < 1 {
+		i = 1
+	} else if i > n-1 {
+		i = n - 1
+	}
+	frac := (lnK - tt.lnK[i-1]) / (tt.lnK[i] - tt.lnK[i-1])
+	return math.Exp(tt.lnT[i-1] + frac*(tt.lnT[i]-tt.lnT[i-1]))
+}
+
+// ehParams contains the k-independent terms of the Eisenstein & Hu (1998)
+// transfer functions. Wavenumbers are in 1/Mpc.
+type ehParams struct {
+	theta2          float64 // (T_CMB / 2.7 K)^2
+	omegaH2, fb, fc float64
+	kEq, kSilk, s   float64
+	alphaC, betaC   float64
+	alphaB, betaB   float64
+	betaNode        float64
+	alphaGamma, sNW float64
+	h100            float64
+}
+
+func (c Cosmology) ehParams() ehParams {
+	theta := TCMB / 2.7
+	h2 := c.H100 * c.H100
+	om, ob := c.OmegaM*h2, c.OmegaB*h2
+	fb := c.OmegaB / c.OmegaM
+	fc := 1 - fb
+
+	zEq := 2.50e4 * om / (theta * theta * theta * theta)
+	kEq := 7.46e-2 * om / (theta * theta)
+
+	b1 := 0.313 * math.Pow(om, -0.419) * (1 + 0.607*math.Pow(om, 0.674))
+	b2 := 0.238 * math.Pow(om, 0.223)
+	zd := 1291 * math.Pow(om, 0.251) / (1 + 0.659*math.Pow(om, 0.828)) *
+		(1 + b1*math.Pow(ob, b2))
+
+	baryonRatio := func(z float64) float64 {
+		return 31.5 * ob / (theta * theta * theta * theta) * (1000 / z)
+	}
+	rd, rEq := baryonRatio(zd), baryonRatio(zEq)
+	s := 2 / (3 * kEq) * math.Sqrt(6/rEq) *
+		math.Log((math.Sqrt(1+rd)+math.Sqrt(rd+rEq))/(1+math.Sqrt(rEq)))
+
+	a1 := math.Pow(46.9*om, 0.670) * (1 + math.Pow(32.1*om, -0.532))
+	a2 := math.Pow(12.0*om, 0.424) * (1 + math.Pow(45.0*om, -0.582))
+	bb1 := 0.944 / (1 + math.Pow(458*om, -0.708))
+	bb2 := math.Pow(0.395*om, -0.0266)
+
+	y := (1 + zEq) / (1 + zd)
+	sy := math.Sqrt(1 + y)
+	g := y * (-6*sy + (2+3*y)*math.Log((sy+1)/(sy-1)))
+
+	return ehParams{
+		theta2:  theta * theta,
+		omegaH2: om, fb: fb, fc: fc,
+		kEq: kEq, s: s,
+		kSilk: 1.6 * math.Pow(ob, 0.52) * math.Pow(om, 0.73) *
+			(1 + math.Pow(10.4*om, -0.95)),
+		alphaC:   math.Pow(a1, -fb) * math.Pow(a2, -fb*fb*fb),
+		betaC:    1 / (1 + bb1*(math.Pow(fc, bb2)-1)),
+		alphaB:   2.07 * kEq * s * math.Pow(1+rd, -0.75) * g,
+		betaB:    0.5 + fb + (3-2*fb)*math.Sqrt(17.2*om*17.2*om+1),
+		betaNode: 8.41 * math.Pow(om, 0.435),
+		alphaGamma: 1 - 0.328*math.Log(431*om)*fb +
+			0.38*math.Log(22.3*om)*fb*fb,
+		sNW:  44.5 * math.Log(9.83/om) / math.Sqrt(1+10*math.Pow(ob, 0.75)),
+		h100: c.H100,
+	}
+}
+
+// ehT0 is the pressureless transfer function of Eisenstein & Hu (1998),
+// equations 19-20.
+func (p *ehParams) ehT0(k, alpha, beta float64) float64 {
+	q := k / (13.41 * p.kEq)
+	l := math.Log(math.E + 1.8*beta*q)
+	c := 14.2/alpha + 386/(1+69.9*math.Pow(q, 1.08))
+	return l / (l + c*q*q)
+}
+
+// transferBAO returns the Eisenstein & Hu (1998) transfer function,
+// including baryon acoustic oscillations, at k in 1/Mpc.
+func (p *ehParams) transferBAO(k float64) float64 {
+	ks := k * p.s
+
+	f := 1 / (1 + math.Pow(ks/5.4, 4))
+	tc := f*p.ehT0(k, 1, p.betaC) + (1-f)*p.ehT0(k, p.alphaC, p.betaC)
+
+	bn := p.betaNode / ks
+	sTilde := p.s / math.Cbrt(1+bn*bn*bn)
+	x := k * sTilde
+	j0 := 1.0
+	if x > 1e-4 {
+		j0 = math.Sin(x) / x
+	}
+	bb := p.betaB / ks
+	tb := (p.ehT0(k, 1, 1)/(1+(ks/5.2)*(ks/5.2)) +
+		p.alphaB/(1+bb*bb*bb)*math.Exp(-math.Pow(k/p.kSilk, 1.4))) * j0
+
+	return p.fb*tb + p.fc*tc
+}
+
+// transferNoBAO returns the zero-baryon-oscillation transfer function of
+// Eisenstein & Hu (1998), equations 29-31, at k in 1/Mpc.
+func (p *ehParams) transferNoBAO(k float64) float64 {
+	ks := 0.43 * k * p.sNW
+	gamma := p.omegaH2 / p.h100 *
+		(p.alphaGamma + (1-p.alphaGamma)/(1+ks*ks*ks*ks))
+	q := k / p.h100 * p.theta2 / gamma
+
+	l := math.Log(2*math.E + 1.8*q)
+	c := 14.2 + 731/(1+62.5*q)
+	return l / (l + c*q*q)
+}
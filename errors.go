@@ -0,0 +1,125 @@
+package halo
+
+import (
+	"fmt"
+)
+
+// MassBoundsError is returned when a halo is requested with a mass outside
+// of the range [Min, Max].
+type MassBoundsError struct {
+	M, Min, Max float64
+}
+
+func (e *MassBoundsError) Error() string {
+	return fmt.Sprintf("halo: mass %.5g is not within halo bounds [%.5g, %.5g]",
+		e.M, e.Min, e.Max)
+}
+
+// EnumError is returned when a function is given a flag value which it does
+// not recognize, or which it does not support in combination with its other
+// arguments. Type is the name of the flag's type and Context, if non-empty,
+// describes the combination which was rejected.
+type EnumError struct {
+	Type    string
+	Value   int
+	Context string
+}
+
+func (e *EnumError) Error() string {
+	if e.Context == "" {
+		return fmt.Sprintf("halo: unrecognized %s %d", e.Type, e.Value)
+	}
+	return fmt.Sprintf("halo: %s %d is not supported for %s",
+		e.Type, e.Value, e.Context)
+}
+
+// BracketError is returned when a root finder is given an interval which
+// does not bracket a root. Quantity names the value being solved for, and
+// FLo and FHi are the residuals at the ends of the interval [Lo, Hi].
+type BracketError struct {
+	Quantity string
+	Lo, Hi   float64
+	FLo, FHi float64
+}
+
+func (e *BracketError) Error() string {
+	return fmt.Sprintf("halo: could not bracket %s: residuals at [%.5g, %.5g] "+
+		"are [%.5g, %.5g]", e.Quantity, e.Lo, e.Hi, e.FLo, e.FHi)
+}
+
+// ConvergenceError is returned when a root finder fails to reach its
+// tolerance. [Lo, Hi] is the last bracket considered and Residual is the
+// residual at its best estimate of the root.
+type ConvergenceError struct {
+	Quantity   string
+	Iterations int
+	Lo, Hi     float64
+	Residual   float64
+}
+
+func (e *ConvergenceError) Error() string {
+	return fmt.Sprintf("halo: %s did not converge after %d iterations: "+
+		"bracket [%.5g, %.5g], residual %.5g",
+		e.Quantity, e.Iterations, e.Lo, e.Hi, e.Residual)
+}
+
+// ConstructionError is returned by the Halo constructors. It records the
+// arguments which the halo was constructed with and wraps the error which
+// caused construction to fail. The wrapped error can be inspected with
+// errors.As.
+type ConstructionError struct {
+	BiasType    BiasType
+	DensityType DensityType
+	M, Z        float64
+	Err         error
+}
+
+func (e *ConstructionError) Error() string {
+	bt := "corrected"
+	if e.BiasType == Biased {
+		bt = "biased"
+	}
+	return fmt.Sprintf("halo: could not construct halo with %s M%v = %.5g "+
+		"at z = %.5g: %v", bt, e.DensityType, e.M, e.Z, e.Err)
+}
+
+func (e *ConstructionError) Unwrap() error { return e.Err }
+
+// BatchError is the error of a single HaloSpec passed to EvalBatch. Index is
+// the position of the spec and Err is the underlying error.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("halo: batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// NilArgumentError is returned when a function is given a nil value for a
+// required interface argument. Type is the name of the interface.
+type NilArgumentError struct {
+	Type string
+}
+
+func (e *NilArgumentError) Error() string {
+	return fmt.Sprintf("halo: nil %s", e.Type)
+}
+
+// RegistryError is returned when a pressure profile name is registered twice
+// or is looked up without having been registered. Registered is true in the
+// first case.
+type RegistryError struct {
+	Name       string
+	Registered bool
+}
+
+func (e *RegistryError) Error() string {
+	if e.Registered {
+		return fmt.Sprintf("halo: pressure profile %q is already registered",
+			e.Name)
+	}
+	return fmt.Sprintf("halo: no pressure profile named %q", e.Name)
+}
@@ -0,0 +1,265 @@
+package halo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// FThermalParams describes a fit for f_th as a multivariate normal
+// distribution over a vector of parameters. Func maps a parameter vector
+// to the corresponding f_th relation.
+type FThermalParams struct {
+	Names []string
+	Mean  []float64
+	// Cov is the len(Mean) x len(Mean) covariance matrix of the parameters.
+	Cov  [][]float64
+	Func func(p []float64) FThermalModel
+}
+
+// FThermalParamsOf returns the parameter distribution of one of the built-in
+// f_th fits. Only fits which publish uncertainties are supported: Nelson2012
+// has the parameters (alpha, n) of f_th = 1 - alpha x^n, and Shaw2010 has
+// the parameters (alpha0, n_nt). Their published uncertainties are treated
+// as independent.
+//
+// Battaglia2013 has the parameters (A0, xc0, alpha0). Battaglia et al.
+// (2013) publish fits to the mean and +/-1 sigma f_th curves rather than
+// parameter uncertainties, so the parameters vary along the single direction
+// d = (p_+ - p_-) / 2 joining the two sigma curves, and Cov = d d^T. Only
+// xc^2 enters f_th, so the magnitude of the +1 sigma xc0 is used.
+func FThermalParamsOf(ftt FThermalType) (*FThermalParams, error) {
+	switch ftt {
+	case Battaglia2013:
+		d := []float64{
+			(a0pBattaglia - a0mBattaglia) / 2,
+			(math.Abs(xc0pBattaglia) - xc0mBattaglia) / 2,
+			(alpha0pBattaglia - alpha0mBattaglia) / 2,
+		}
+		cov := make([][]float64, len(d))
+		for i := range cov {
+			cov[i] = make([]float64, len(d))
+			for j := range cov[i] {
+				cov[i][j] = d[i] * d[j]
+			}
+		}
+		return &FThermalParams{
+			Names: []string{"A0", "xc0", "alpha0"},
+			Mean:  []float64{a0Battaglia, xc0Battaglia, alpha0Battaglia},
+			Cov:   cov,
+			Func: func(p []float64) FThermalModel {
+				return battagliaFThermalFunc(p[0], p[1], p[2])
+			},
+		}, nil
+	case Nelson2012:
+		return &FThermalParams{
+			Names: []string{"alpha", "n"},
+			Mean:  []float64{alphaNelson, nNelson},
+			Cov:   diagonalCov(alphaPNelson-alphaNelson, nPNelson-nNelson),
+			Func: func(p []float64) FThermalModel {
+				return nelsonFThermalFunc(p[0], p[1])
+			},
+		}, nil
+	case Shaw2010:
+		return &FThermalParams{
+			Names: []string{"alpha0", "nnt"},
+			Mean:  []float64{alpha0Shaw, nntShaw},
+			Cov:   diagonalCov(alpha0SigShaw, nntSigShaw),
+			Func: func(p []float64) FThermalModel {
+				return shawFThermalFunc(p[0], p[1])
+			},
+		}, nil
+	}
+	return nil, &EnumError{
+		Type: "FThermalType", Value: int(ftt), Context: "FThermalParams",
+	}
+}
+
+// Relative rounding error allowed in the pivots of a covariance matrix.
+const choleskyTolerance = 1e-12
+
+func diagonalCov(sigmas ...float64) [][]float64 {
+	cov := make([][]float64, len(sigmas))
+	for i, sig := range sigmas {
+		cov[i] = make([]float64, len(sigmas))
+		cov[i][i] = sig * sig
+	}
+	return cov
+}
+
+// Validate returns an error if the dimensions of p are inconsistent, Func is
+// nil, or Cov is not symmetric positive semi-definite.
+func (p *FThermalParams) Validate() error {
+	_, err := p.cholesky()
+	return err
+}
+
+// cholesky returns the lower-triangular matrix L with Cov = L L^T. Zero
+// variances and singular matrices are allowed, so parameters can be held
+// fixed or varied together.
+func (p *FThermalParams) cholesky() ([][]float64, error) {
+	n := len(p.Mean)
+	if p.Func == nil {
+		return nil, fmt.Errorf("halo: FThermalParams has no Func")
+	} else if len(p.Cov) != n {
+		return nil, fmt.Errorf("halo: FThermalParams has %d parameters "+
+			"but a %d x %d covariance matrix", n, len(p.Cov), len(p.Cov))
+	}
+	for i := range p.Cov {
+		if len(p.Cov[i]) != n {
+			return nil, fmt.Errorf("halo: FThermalParams covariance " +
+				"matrix is not square")
+		}
+		for j := 0; j < i; j++ {
+			if p.Cov[i][j] != p.Cov[j][i] {
+				return nil, fmt.Errorf("halo: FThermalParams covariance " +
+					"matrix is not symmetric")
+			}
+		}
+	}
+
+	L := make([][]float64, n)
+	for i := range L {
+		L[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := p.Cov[i][j]
+			for k := 0; k < j; k++ {
+				sum -= L[i][k] * L[j][k]
+			}
+
+			if i == j {
+				// Singular matrices leave pivots which are zero up to
+				// rounding error.
+				tol := choleskyTolerance * p.Cov[i][i]
+				if sum < -tol {
+					return nil, fmt.Errorf("halo: FThermalParams " +
+						"covariance matrix is not positive semi-definite")
+				} else if sum <= tol {
+					sum = 0
+				}
+				L[i][i] = math.Sqrt(sum)
+			} else if L[j][j] > 0 {
+				L[i][j] = sum / L[j][j]
+			}
+		}
+	}
+	return L, nil
+}
+
+// Sample draws n parameter vectors from p using rng.
+func (p *FThermalParams) Sample(rng *rand.Rand, n int) ([][]float64, error) {
+	L, err := p.cholesky()
+	if err != nil {
+		return nil, err
+	}
+
+	dim := len(p.Mean)
+	samples := make([][]float64, n)
+	z := make([]float64, dim)
+	for i := range samples {
+		for j := range z {
+			z[j] = rng.NormFloat64()
+		}
+		samples[i] = make([]float64, dim)
+		for j := 0; j < dim; j++ {
+			samples[i][j] = p.Mean[j]
+			for k := 0; k <= j; k++ {
+				samples[i][j] += L[j][k] * z[k]
+			}
+		}
+	}
+	return samples, nil
+}
+
+// BiasDistribution contains the mass bias, C500.M / M500cBias, of halos
+// constructed from sampled f_th parameters. Params[i] is the parameter
+// vector used to construct the halo with bias Bias[i]. Draws for which no
+// halo could be constructed (e.g. because they are unphysical) are excluded
+// from Params and Bias and are recorded in Failed.
+type BiasDistribution struct {
+	Params [][]float64
+	Bias   []float64
+	Failed []*SampleError
+	sorted []float64
+}
+
+// SampleError records a sampled f_th parameter vector which a halo could not
+// be constructed from. Index is the position of the draw in the sequence
+// generated from the seed.
+type SampleError struct {
+	Index  int
+	Params []float64
+	Err    error
+}
+
+func (e *SampleError) Error() string {
+	return fmt.Sprintf("halo: sample %d with f_th parameters %v: %v",
+		e.Index, e.Params, e.Err)
+}
+
+func (e *SampleError) Unwrap() error { return e.Err }
+
+// SampleBias draws n realizations of the f_th parameters from p and
+// constructs a halo with each of them using New. The sequence of draws is
+// fully determined by seed. An error is returned if p is invalid or if no
+// halo could be constructed from any of the draws, in which case the error
+// is the *SampleError of the first draw.
+func SampleBias(p *FThermalParams, n int, seed int64, cosmology cosmo.Cosmology, pp PressureProfile, mp MassProfile, cFunc num.Func1D, bt BiasType, m500c, z float64) (*BiasDistribution, error) {
+	params, err := p.Sample(rand.New(rand.NewSource(seed)), n)
+	if err != nil {
+		return nil, err
+	}
+
+	dist := &BiasDistribution{}
+	for i := range params {
+		h, err := New(cosmology, p.Func(params[i]), pp, mp, cFunc, bt, m500c, z)
+		if err != nil {
+			dist.Failed = append(dist.Failed, &SampleError{i, params[i], err})
+			continue
+		}
+		dist.Params = append(dist.Params, params[i])
+		dist.Bias = append(dist.Bias, h.C500.M/h.M500cBias)
+	}
+	if n > 0 && len(dist.Bias) == 0 {
+		return nil, dist.Failed[0]
+	}
+
+	dist.sorted = append([]float64{}, dist.Bias...)
+	sort.Float64s(dist.sorted)
+	return dist, nil
+}
+
+// Percentile returns the q-th percentile of the bias distribution, for
+// 0 <= q <= 100, linearly interpolating between samples.
+func (d *BiasDistribution) Percentile(q float64) float64 {
+	n := len(d.sorted)
+	if n == 0 || q < 0 || q > 100 {
+		return math.NaN()
+	}
+
+	pos := q / 100 * float64(n-1)
+	i := int(pos)
+	if i >= n-1 {
+		return d.sorted[n-1]
+	}
+	frac := pos - float64(i)
+	return d.sorted[i]*(1-frac) + d.sorted[i+1]*frac
+}
+
+// Mean returns the mean of the bias distribution.
+func (d *BiasDistribution) Mean() float64 {
+	if len(d.Bias) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, b := range d.Bias {
+		sum += b
+	}
+	return sum / float64(len(d.Bias))
+}
@@ -0,0 +1,116 @@
+package halo
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+func TestSampleReproducible(t *testing.T) {
+	for _, ftt := range []FThermalType{Battaglia2013, Nelson2012, Shaw2010} {
+		p, err := FThermalParamsOf(ftt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := p.Sample(rand.New(rand.NewSource(7)), 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := p.Sample(rand.New(rand.NewSource(7)), 50)
+		c, _ := p.Sample(rand.New(rand.NewSource(8)), 50)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("FThermalType %d: draws with the same seed differ", ftt)
+		}
+		if reflect.DeepEqual(a, c) {
+			t.Errorf("FThermalType %d: draws with different seeds are "+
+				"identical", ftt)
+		}
+	}
+}
+
+// TestSampleMoments checks the mean and covariance of a large number of
+// draws against those of the distribution.
+func TestSampleMoments(t *testing.T) {
+	const n = 20000
+	for _, ftt := range []FThermalType{Battaglia2013, Nelson2012, Shaw2010} {
+		p, err := FThermalParamsOf(ftt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, err := p.Sample(rand.New(rand.NewSource(1)), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dim := len(p.Mean)
+		mean := make([]float64, dim)
+		for _, s := range samples {
+			for i := range s {
+				mean[i] += s[i] / n
+			}
+		}
+		for i := 0; i < dim; i++ {
+			sig := math.Sqrt(p.Cov[i][i])
+			// Five standard errors of the mean.
+			if d := math.Abs(mean[i] - p.Mean[i]); d > 5*sig/math.Sqrt(n) {
+				t.Errorf("FThermalType %d: mean %s = %g, expected %g", ftt,
+					p.Names[i], mean[i], p.Mean[i])
+			}
+			for j := 0; j < dim; j++ {
+				cov := 0.0
+				for _, s := range samples {
+					cov += (s[i] - mean[i]) * (s[j] - mean[j]) / (n - 1)
+				}
+				tol := 5 * math.Sqrt(2/float64(n)) * sig * math.Sqrt(p.Cov[j][j])
+				if d := math.Abs(cov - p.Cov[i][j]); d > tol {
+					t.Errorf("FThermalType %d: Cov(%s, %s) = %g, expected %g",
+						ftt, p.Names[i], p.Names[j], cov, p.Cov[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestSampleBias(t *testing.T) {
+	c := cosmo.Fiducial
+	cFunc, err := ConcentrationFunc(c, Bhattacharya2013, 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := FThermalParamsOf(Nelson2012)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample := func(seed int64) *BiasDistribution {
+		dist, err := SampleBias(p, 20, seed, c, BattagliaAGN2012,
+			MassProfile{}, cFunc, Corrected, 3e14, 0.3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dist
+	}
+	a, b := sample(3), sample(3)
+	if !reflect.DeepEqual(a.Bias, b.Bias) || !reflect.DeepEqual(a.Params, b.Params) {
+		t.Errorf("SampleBias with the same seed gave different results")
+	}
+	if len(a.Bias)+len(a.Failed) != 20 {
+		t.Errorf("%d successful and %d failed draws, expected 20 in total",
+			len(a.Bias), len(a.Failed))
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, bias := range a.Bias {
+		min, max = math.Min(min, bias), math.Max(max, bias)
+	}
+	if a.Percentile(0) != min || a.Percentile(100) != max {
+		t.Errorf("Percentile(0), Percentile(100) = %g, %g, expected %g, %g",
+			a.Percentile(0), a.Percentile(100), min, max)
+	}
+	if mean := a.Mean(); mean < min || mean > max {
+		t.Errorf("Mean() = %g is outside [%g, %g]", mean, min, max)
+	}
+}
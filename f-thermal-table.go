@@ -0,0 +1,358 @@
+package halo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExtrapolationType is a flag which specifies how a table is evaluated
+// outside of the range of its grid.
+type ExtrapolationType int
+
+const (
+	// ClampExtrapolation uses the value at the nearest edge of the grid.
+	ClampExtrapolation ExtrapolationType = iota
+	// LinearExtrapolation continues the spline linearly using its slope at
+	// the nearest edge of the grid.
+	LinearExtrapolation
+	// NaNExtrapolation returns NaN outside of the grid. A Halo whose
+	// construction requires such a value will fail with a
+	// *ConvergenceError.
+	NaNExtrapolation
+)
+
+// FThermalExtrapolation gives the ExtrapolationType used along each axis of
+// an FThermalTable.
+type FThermalExtrapolation struct {
+	X, LogM, Z ExtrapolationType
+}
+
+// FThermalTable is f_th tabulated on a grid of x = r / R500c, log10(M500c),
+// and z, where R500c and M500c are the true radius and mass of the halo.
+// Values are found through monotone piecewise cubic (Fritsch-Butland)
+// interpolation along each axis, so a monotone table remains monotone
+// between grid points and the interpolant has a continuous first
+// derivative. Axes with a single grid point are treated as constant.
+type FThermalTable struct {
+	x, logM, z []float64
+	// fTh[(iz*len(logM) + im)*len(x) + ix]
+	fTh []float64
+	// dfdx contains the x-slopes of the splines through each row of fTh.
+	dfdx []float64
+	ext  FThermalExtrapolation
+}
+
+// NewFThermalTable creates an FThermalTable from the strictly increasing
+// grid axes x, m500c (in M_sun), and z. fTh has length
+// len(x) * len(m500c) * len(z) and is indexed as
+// fTh[(iz*len(m500c) + im)*len(x) + ix].
+func NewFThermalTable(x, m500c, z, fTh []float64, ext FThermalExtrapolation) (*FThermalTable, error) {
+	logM := make([]float64, len(m500c))
+	for i, m := range m500c {
+		if m <= 0 {
+			return nil, &ParameterError{"FThermalTable", "M500c", m, "M500c > 0"}
+		}
+		logM[i] = math.Log10(m)
+	}
+
+	axes := []struct {
+		name string
+		vals []float64
+	}{{"x", x}, {"M500c", logM}, {"z", z}}
+	for _, axis := range axes {
+		if len(axis.vals) == 0 {
+			return nil, fmt.Errorf("halo: FThermalTable has no %s values",
+				axis.name)
+		}
+		for i := 1; i < len(axis.vals); i++ {
+			if !(axis.vals[i] > axis.vals[i-1]) {
+				return nil, fmt.Errorf("halo: FThermalTable %s values are "+
+					"not strictly increasing", axis.name)
+			}
+		}
+	}
+
+	if len(fTh) != len(x)*len(logM)*len(z) {
+		return nil, fmt.Errorf("halo: FThermalTable has %d f_th values, "+
+			"but its grid has %d points", len(fTh), len(x)*len(logM)*len(z))
+	}
+	for _, f := range fTh {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("halo: FThermalTable contains non-finite "+
+				"f_th value %g", f)
+		}
+	}
+	for _, e := range []ExtrapolationType{ext.X, ext.LogM, ext.Z} {
+		if e < ClampExtrapolation || e > NaNExtrapolation {
+			return nil, &EnumError{Type: "ExtrapolationType", Value: int(e)}
+		}
+	}
+
+	t := &FThermalTable{
+		x:    append([]float64{}, x...),
+		logM: logM,
+		z:    append([]float64{}, z...),
+		fTh:  append([]float64{}, fTh...),
+		dfdx: make([]float64, len(fTh)),
+		ext:  ext,
+	}
+	for i := 0; i < len(fTh); i += len(x) {
+		monotoneSlopes(t.x, t.fTh[i:i+len(x)], t.dfdx[i:i+len(x)])
+	}
+
+	return t, nil
+}
+
+// ReadFThermalTable reads an FThermalTable from an ASCII table with four
+// columns: r / R500c, M500c in M_sun, z, and f_th. Columns may be separated
+// by whitespace or commas, and blank lines and lines beginning with '#'
+// are ignored. Every combination of the distinct x, M500c, and z values
+// must appear exactly once, in any order.
+func ReadFThermalTable(rd io.Reader, ext FThermalExtrapolation) (*FThermalTable, error) {
+	type row struct{ x, m, z, f float64 }
+	rows := []row{}
+
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("halo: f_th table line %d has %d "+
+				"columns, expected 4", line, len(fields))
+		}
+
+		var vals [4]float64
+		for i, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("halo: f_th table line %d: %v",
+					line, err)
+			}
+			vals[i] = v
+		}
+		rows = append(rows, row{vals[0], vals[1], vals[2], vals[3]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	x, m, z := []float64{}, []float64{}, []float64{}
+	for _, r := range rows {
+		x, m, z = append(x, r.x), append(m, r.m), append(z, r.z)
+	}
+	x, m, z = uniqueSorted(x), uniqueSorted(m), uniqueSorted(z)
+
+	fTh := make([]float64, len(x)*len(m)*len(z))
+	seen := make([]bool, len(fTh))
+	for _, r := range rows {
+		ix := sort.SearchFloat64s(x, r.x)
+		im := sort.SearchFloat64s(m, r.m)
+		iz := sort.SearchFloat64s(z, r.z)
+		i := (iz*len(m)+im)*len(x) + ix
+		if seen[i] {
+			return nil, fmt.Errorf("halo: f_th table contains duplicate "+
+				"point (x, M500c, z) = (%g, %g, %g)", r.x, r.m, r.z)
+		}
+		seen[i] = true
+		fTh[i] = r.f
+	}
+	if len(rows) != len(fTh) {
+		return nil, fmt.Errorf("halo: f_th table has %d points, but its "+
+			"grid requires %d", len(rows), len(fTh))
+	}
+
+	return NewFThermalTable(x, m, z, fTh, ext)
+}
+
+// ReadFThermalTableFile reads an FThermalTable from the named file. See
+// ReadFThermalTable for the format.
+func ReadFThermalTableFile(fname string, ext FThermalExtrapolation) (*FThermalTable, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFThermalTable(f, ext)
+}
+
+func uniqueSorted(xs []float64) []float64 {
+	sort.Float64s(xs)
+	out := xs[:0]
+	for i, x := range xs {
+		if i == 0 || x != out[len(out)-1] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// Eval returns the tabulated f_th at x = r / R500c for a halo with the given
+// true M500c and redshift. The x-slopes are tabulated, but the slopes along
+// log10(M500c) and z depend on x, so only the (at most four by four) rows of
+// the table which determine the splines around (M500c, z) are evaluated.
+func (t *FThermalTable) Eval(x, m500c, z float64) float64 {
+	nx, nm := len(t.x), len(t.logM)
+	logM := math.Log10(m500c)
+
+	fx := func(iz, im int) float64 {
+		i := (iz*nm + im) * nx
+		return hermiteEval(t.x, t.fTh[i:i+nx], t.dfdx[i:i+nx], x, t.ext.X)
+	}
+	fz := func(iz int) float64 {
+		fm := func(im int) float64 { return fx(iz, im) }
+		return localSplineEval(t.logM, fm, logM, t.ext.LogM)
+	}
+	return localSplineEval(t.z, fz, z, t.ext.Z)
+}
+
+// RadialFunc returns a RadialFuncType which evaluates t at the true R500c,
+// M500c, and redshift of a halo. It can be used anywhere the output of
+// FThermalFunc can.
+func (t *FThermalTable) RadialFunc() RadialFuncType {
+	return func(h *Halo, r float64) float64 {
+		return t.Eval(r/h.C500.R, h.C500.M, h.Z)
+	}
+}
+
+// monotoneSlopes computes the Fritsch-Butland slopes, dy, of the monotone
+// piecewise cubic Hermite interpolant through (x, y).
+func monotoneSlopes(x, y, dy []float64) {
+	yi := func(i int) float64 { return y[i] }
+	for k := range dy {
+		dy[k] = nodeSlope(x, yi, k)
+	}
+}
+
+// nodeSlope returns the Fritsch-Butland slope at node k of the monotone
+// piecewise cubic Hermite interpolant through (x, y(i)). Only the values at
+// nodes k-2 through k+2 are used.
+func nodeSlope(x []float64, y func(int) float64, k int) float64 {
+	n := len(x)
+	if n == 1 {
+		return 0
+	} else if n == 2 {
+		return (y(1) - y(0)) / (x[1] - x[0])
+	}
+
+	switch k {
+	case 0:
+		return endSlope(x[1]-x[0], x[2]-x[1],
+			(y(1)-y(0))/(x[1]-x[0]), (y(2)-y(1))/(x[2]-x[1]))
+	case n - 1:
+		return endSlope(x[n-1]-x[n-2], x[n-2]-x[n-3],
+			(y(n-1)-y(n-2))/(x[n-1]-x[n-2]), (y(n-2)-y(n-3))/(x[n-2]-x[n-3]))
+	}
+
+	h0, h1 := x[k]-x[k-1], x[k+1]-x[k]
+	d0, d1 := (y(k)-y(k-1))/h0, (y(k+1)-y(k))/h1
+	if d0*d1 <= 0 {
+		return 0
+	}
+	return 3 * (h0 + h1) / ((2*h1+h0)/d0 + (h1+2*h0)/d1)
+}
+
+// endSlope computes a shape-preserving three-point estimate of the slope at
+// the end of a spline, where h0 and d0 are the width and secant slope of the
+// end interval and h1 and d1 are those of its neighbor.
+func endSlope(h0, h1, d0, d1 float64) float64 {
+	s := ((2*h0+h1)*d0 - h0*d1) / (h0 + h1)
+	if s*d0 <= 0 {
+		return 0
+	} else if d0*d1 <= 0 && math.Abs(s) > math.Abs(3*d0) {
+		return 3 * d0
+	}
+	return s
+}
+
+// splineEval evaluates the monotone spline through (x, y) at xi.
+func splineEval(x, y []float64, xi float64, ext ExtrapolationType) float64 {
+	return localSplineEval(x, func(i int) float64 { return y[i] }, xi, ext)
+}
+
+// localSplineEval evaluates the monotone spline through (x, y(i)) at xi.
+// The slopes at the ends of the interval containing xi only depend on the
+// values at the nodes within two of it, so y is only called for those nodes
+// and no other slopes are computed.
+func localSplineEval(x []float64, y func(int) float64, xi float64, ext ExtrapolationType) float64 {
+	n := len(x)
+	if n == 1 {
+		if xi != x[0] && ext == NaNExtrapolation {
+			return math.NaN()
+		}
+		return y(0)
+	}
+
+	k := sort.SearchFloat64s(x, xi)
+	if k == 0 {
+		k = 1
+	} else if k == n {
+		k = n - 1
+	}
+	lo, hi := k-2, k+1
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > n-1 {
+		hi = n - 1
+	}
+
+	var ys, dys [4]float64
+	for i := lo; i <= hi; i++ {
+		ys[i-lo] = y(i)
+	}
+	yi := func(i int) float64 { return ys[i-lo] }
+	dys[k-1-lo] = nodeSlope(x, yi, k-1)
+	dys[k-lo] = nodeSlope(x, yi, k)
+
+	w := hi - lo + 1
+	return hermiteEval(x[lo:hi+1], ys[:w], dys[:w], xi, ext)
+}
+
+// hermiteEval evaluates the cubic Hermite interpolant through (x, y) with
+// slopes dy at xi.
+func hermiteEval(x, y, dy []float64, xi float64, ext ExtrapolationType) float64 {
+	n := len(x)
+	if n == 1 {
+		if xi != x[0] && ext == NaNExtrapolation {
+			return math.NaN()
+		}
+		return y[0]
+	}
+
+	if xi < x[0] || xi > x[n-1] {
+		end := 0
+		if xi > x[n-1] {
+			end = n - 1
+		}
+		switch ext {
+		case ClampExtrapolation:
+			return y[end]
+		case LinearExtrapolation:
+			return y[end] + dy[end]*(xi-x[end])
+		}
+		return math.NaN()
+	}
+
+	k := sort.SearchFloat64s(x, xi)
+	if k == 0 {
+		k = 1
+	}
+	h := x[k] - x[k-1]
+	t := (xi - x[k-1]) / h
+	t2, t3 := t*t, t*t*t
+
+	return (2*t3-3*t2+1)*y[k-1] + (t3-2*t2+t)*h*dy[k-1] +
+		(-2*t3+3*t2)*y[k] + (t3-t2)*h*dy[k]
+}
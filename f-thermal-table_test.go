@@ -0,0 +1,163 @@
+package halo
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// monotoneTable returns an FThermalTable on an uneven grid whose values
+// increase with x and M500c and decrease with z, with sharp changes in
+// slope where an interpolant which is not monotone would overshoot.
+func monotoneTable(t *testing.T, ext FThermalExtrapolation) *FThermalTable {
+	x := []float64{0.05, 0.1, 0.3, 0.35, 1, 2.5, 4}
+	m500c := []float64{1e13, 3e13, 1e14, 1.2e14, 1e15}
+	z := []float64{0, 0.25, 1, 2}
+
+	fTh := make([]float64, len(x)*len(m500c)*len(z))
+	for iz := range z {
+		for im := range m500c {
+			for ix := range x {
+				step := 0.0
+				if x[ix] > 0.32 {
+					step = 0.3
+				}
+				fTh[(iz*len(m500c)+im)*len(x)+ix] = 0.4 + step +
+					0.01*x[ix] + 0.05*float64(im/3) - 0.02*float64(iz)
+			}
+		}
+	}
+
+	table, err := NewFThermalTable(x, m500c, z, fTh, ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+// fullEval evaluates t by computing the slopes of every intermediate
+// spline.
+func fullEval(t *FThermalTable, x, m500c, z float64) float64 {
+	nx, nm, nz := len(t.x), len(t.logM), len(t.z)
+	fz, dfz := make([]float64, nz), make([]float64, nz)
+	fm, dfm := make([]float64, nm), make([]float64, nm)
+	for iz := 0; iz < nz; iz++ {
+		for im := 0; im < nm; im++ {
+			i := (iz*nm + im) * nx
+			fm[im] = hermiteEval(t.x, t.fTh[i:i+nx], t.dfdx[i:i+nx],
+				x, t.ext.X)
+		}
+		monotoneSlopes(t.logM, fm, dfm)
+		fz[iz] = hermiteEval(t.logM, fm, dfm, math.Log10(m500c), t.ext.LogM)
+	}
+	monotoneSlopes(t.z, fz, dfz)
+	return hermiteEval(t.z, fz, dfz, z, t.ext.Z)
+}
+
+func TestFThermalTableNodes(t *testing.T) {
+	table := monotoneTable(t, FThermalExtrapolation{})
+	nx, nm := len(table.x), len(table.logM)
+	for iz, z := range table.z {
+		for im, logM := range table.logM {
+			for ix, x := range table.x {
+				want := table.fTh[(iz*nm+im)*nx+ix]
+				got := table.Eval(x, math.Pow(10, logM), z)
+				if math.Abs(got-want) > 1e-12 {
+					t.Errorf("Eval(%g, 10^%g, %g) = %g, tabulated value "+
+						"is %g", x, logM, z, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFThermalTableMonotone(t *testing.T) {
+	table := monotoneTable(t, FThermalExtrapolation{})
+	const n = 200
+
+	for _, m := range []float64{1e13, 5e13, 1.1e14, 7e14} {
+		for _, z := range []float64{0, 0.6, 1.5} {
+			prev := math.Inf(-1)
+			for i := 0; i <= n; i++ {
+				x := 0.05 + (4-0.05)*float64(i)/n
+				f := table.Eval(x, m, z)
+				if f < prev {
+					t.Fatalf("f_th(x = %g, M500c = %g, z = %g) = %.10g "+
+						"decreases from %.10g", x, m, z, f, prev)
+				}
+				prev = f
+			}
+		}
+	}
+
+	for _, x := range []float64{0.2, 0.33, 3} {
+		prev := math.Inf(1)
+		for i := 0; i <= n; i++ {
+			z := 2 * float64(i) / n
+			f := table.Eval(x, 2e14, z)
+			if f > prev {
+				t.Fatalf("f_th(x = %g, z = %g) = %.10g increases from %.10g",
+					x, z, f, prev)
+			}
+			prev = f
+		}
+	}
+}
+
+func TestFThermalTableLocalSlopes(t *testing.T) {
+	exts := []FThermalExtrapolation{
+		{}, {LinearExtrapolation, LinearExtrapolation, LinearExtrapolation},
+	}
+	for _, ext := range exts {
+		table := monotoneTable(t, ext)
+		for _, x := range []float64{0.01, 0.07, 0.32, 1, 5} {
+			for _, m := range []float64{3e12, 1e13, 2e13, 1.1e14, 3e15} {
+				for _, z := range []float64{0, 0.1, 0.25, 1.7, 3} {
+					got, want := table.Eval(x, m, z), fullEval(table, x, m, z)
+					if math.Abs(got-want) > 1e-14 {
+						t.Errorf("Eval(%g, %g, %g) = %.15g, expected %.15g",
+							x, m, z, got, want)
+					}
+				}
+			}
+		}
+
+		allocs := testing.AllocsPerRun(100, func() {
+			table.Eval(0.5, 2e14, 0.3)
+		})
+		if allocs != 0 {
+			t.Errorf("Eval makes %g allocations", allocs)
+		}
+	}
+}
+
+func TestReadFThermalTable(t *testing.T) {
+	text := `# x, M500c, z, f_th
+0.1, 1e14, 0, 0.8
+1, 1e14, 0, 0.9
+
+0.1 1e15 0 0.85
+1 1e15 0 0.95
+`
+	table, err := ReadFThermalTable(strings.NewReader(text),
+		FThermalExtrapolation{LogM: NaNExtrapolation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := table.Eval(1, 1e15, 0); f != 0.95 {
+		t.Errorf("f_th(1, 1e15, 0) = %g, expected 0.95", f)
+	}
+	if f := table.Eval(0.1, 1e16, 0); !math.IsNaN(f) {
+		t.Errorf("f_th(0.1, 1e16, 0) = %g, expected NaN", f)
+	}
+
+	if _, err := ReadFThermalTable(strings.NewReader(text+"1 1e15 0 0.9\n"),
+		FThermalExtrapolation{}); err == nil {
+		t.Errorf("duplicate point did not cause an error")
+	}
+	missing := strings.Replace(text, "1, 1e14, 0, 0.9", "", 1)
+	if _, err := ReadFThermalTable(strings.NewReader(missing),
+		FThermalExtrapolation{}); err == nil {
+		t.Errorf("missing point did not cause an error")
+	}
+}
@@ -13,6 +13,9 @@ const (
 	Battaglia2012 FThermalType = iota
 	Battaglia2013
 	Nelson2012
+	Shaw2010
+	Nelson2014
+	ShiKomatsu2014
 )
 
 type FThermalCurveType int
@@ -57,101 +60,388 @@ const (
 	alphaNmBattaglia = -0.18
 	aNzBattaglia = -0.14
 	aNmBattaglia = -0.027
+
+	alpha0Shaw = 0.18
+	alpha0SigShaw = 0.06
+	betaShaw = 0.5
+	nntShaw = 0.8
+	nntSigShaw = 0.25
+	nmShaw = 0.2
+	pivotMassShaw = 3e14
+	// f_nt is capped so that it is never larger than one inside this
+	// fraction of R500c.
+	xMaxShaw = 4.0
+
+	// Nelson et al. (2014) measure radii in units of R200m.
+	aNelson14 = 0.452
+	bNelson14 = 0.841
+	gammaNelson14 = 1.628
+
+	etaShiKomatsu = 0.7
+	betaShiKomatsu = 1.0
+
+	// Mean mass accretion rate of Fakhouri, Ma, & Boylan-Kolchin (2010).
+	accretionAmpFakhouri = 46.1
+	accretionNmFakhouri = 1.1
+	accretionNzFakhouri = 1.11
+	accretionPivotFakhouri = 1e12
+	yearMks = 3.15576e7
 )
 
 type RadialFuncType func(h *Halo, r float64) float64
 
-// FThermalFunc creates a function which calculates fThermal at some radius
-// r.  The return function takes the parameters f(r, r500, m500, z).  The
-// calculation is done as if r, r500, and m500 are the true mass and radii
-// of the halo.
-func FThermalFunc(ftt FThermalType, ftct FThermalCurveType) RadialFuncType {
+// FThermal calls f. It allows any RadialFuncType to be used as an
+// FThermalModel.
+func (f RadialFuncType) FThermal(h *Halo, r float64) float64 { return f(h, r) }
+
+// FThermalModel is a relation for f_th = P_thermal / P_effective as a
+// function of radius. Any RadialFuncType is an FThermalModel.
+//
+// A Halo may call FThermal from multiple goroutines at once, so
+// implementations must be safe for concurrent use. The built-in models
+// are stateless.
+type FThermalModel interface {
+	FThermal(h *Halo, r float64) float64
+}
+
+// FThermalSlopeModel is an FThermalModel which can compute its logarithmic
+// slope, d ln f_th / d ln r, analytically. BetaBias is found numerically
+// for FThermalModels which do not implement it.
+type FThermalSlopeModel interface {
+	FThermalModel
+	DLnFThermalDLnR(h *Halo, r float64) float64
+}
+
+// FThermalCurvatureModel is an FThermalSlopeModel which can also compute
+// d^2 ln f_th / d ln r^2 analytically. If a Halo's pressure profile is a
+// PressureCurvatureProfile, its biased density is found without numerical
+// derivatives.
+type FThermalCurvatureModel interface {
+	FThermalSlopeModel
+	D2LnFThermalDLnR2(h *Halo, r float64) float64
+}
+
+// fThermalFit is a fitted f_th relation with an analytic slope and
+// curvature.
+type fThermalFit struct {
+	f, dLnF, d2LnF RadialFuncType
+}
+
+func (fit fThermalFit) FThermal(h *Halo, r float64) float64 {
+	return fit.f(h, r)
+}
+
+func (fit fThermalFit) DLnFThermalDLnR(h *Halo, r float64) float64 {
+	return fit.dLnF(h, r)
+}
+
+func (fit fThermalFit) D2LnFThermalDLnR2(h *Halo, r float64) float64 {
+	return fit.d2LnF(h, r)
+}
+
+// powerLawFit returns the fThermalFit of f_th = 1 - fNT, where fNT is
+// proportional to r^n.
+func powerLawFit(fNT RadialFuncType, n float64) fThermalFit {
+	return fThermalFit{
+		f: func(h *Halo, r float64) float64 { return 1 - fNT(h, r) },
+		dLnF: func(h *Halo, r float64) float64 {
+			return -n * fNT(h, r) / (1 - fNT(h, r))
+		},
+		d2LnF: func(h *Halo, r float64) float64 {
+			g := fNT(h, r)
+			return -n * n * g / ((1 - g) * (1 - g))
+		},
+	}
+}
+
+// FThermalFunc creates a model which calculates fThermal at some radius
+// r. The calculation is done as if r, r500, and m500 are the true mass and
+// radii of the halo. The returned model is an FThermalCurvatureModel.
+func FThermalFunc(ftt FThermalType, ftct FThermalCurveType) (FThermalModel, error) {
 	switch ftt {
 	case Nelson2012:
 		switch ftct {
 		case MeanCurve:
-			return func(h * Halo, r float64) float64 {
-				return 1 - alphaNelson * math.Pow(r / h.C500.R, nNelson)
-			}
+			return nelsonFThermalFunc(alphaNelson, nNelson), nil
 		case PlusSigmaCurve:
-			return func(h * Halo, r float64) float64 {
-				return 1 - alphaPNelson * math.Pow(r / h.C500.R, nPNelson)
-			}
+			return nelsonFThermalFunc(alphaPNelson, nPNelson), nil
 		case MinusSigmaCurve:
-			return func(h * Halo, r float64) float64 {
-				return 1 - alphaMNelson * math.Pow(r / h.C500.R, nMNelson)
-			}
-		default:
-			panic("Unrecognized ftct")
+			return nelsonFThermalFunc(alphaMNelson, nMNelson), nil
 		}
+		return nil, &EnumError{Type: "FThermalCurveType", Value: int(ftct)}
 
 	case Battaglia2012:
 		switch ftct {
 		case MeanCurve:
 			// This is measured from simulations, so the pivot radius is
 			// the corrected r500.
-			return func(h *Halo, r float64) float64 {
+			fNT := func(h *Halo, r float64) float64 {
 				x := r / h.C500.R
-				return 1.0 - a0AmpBattaglia*
+				return a0AmpBattaglia*
 					(math.Pow(1.0+h.Z, betaAmpBattaglia)*
 						math.Pow(h.C500.M/pivotMassBattaglia, nmAmpBattaglia)*
 						math.Pow(x, nrAmpBattaglia))
 			}
-		default:
-			panic("Only MeanCurve is accepted for Battaglia2012")
+			return powerLawFit(fNT, nrAmpBattaglia), nil
+		}
+		return nil, &EnumError{
+			Type: "FThermalCurveType", Value: int(ftct),
+			Context: "Battaglia2012",
 		}
 	case Battaglia2013:
-		makeFTh := func(A0, xc0, alpha0 float64) RadialFuncType {
-			return func(h *Halo, r float64) float64 {
-				x := r / h.C500.R
-				mFrac := h.C500.M / pivotMassBattaglia
-
-				xc := xc0 * math.Pow(1.0+h.Z, xcNzBattaglia) *
-					math.Pow(mFrac, xcNmBattaglia)
-				alpha := alpha0 * math.Pow(1.0+h.Z, alphaNzBattaglia) *
-					math.Pow(mFrac, alphaNmBattaglia)
-				A := A0 * math.Pow(1.0+h.Z, aNzBattaglia) *
-					math.Pow(mFrac, aNmBattaglia)
-
-				return A * math.Pow(1.0+math.Pow(x/xc, 2.0), -alpha)
-			}
+		switch ftct {
+		case MeanCurve:
+			return battagliaFThermalFunc(a0Battaglia, xc0Battaglia,
+				alpha0Battaglia), nil
+		case PlusSigmaCurve:
+			return battagliaFThermalFunc(a0pBattaglia, xc0pBattaglia,
+				alpha0pBattaglia), nil
+		case MinusSigmaCurve:
+			return battagliaFThermalFunc(a0mBattaglia, xc0mBattaglia,
+				alpha0mBattaglia), nil
 		}
 
+		return nil, &EnumError{Type: "FThermalCurveType", Value: int(ftct)}
+
+	case Shaw2010:
 		switch ftct {
 		case MeanCurve:
-			return makeFTh(a0Battaglia, xc0Battaglia, alpha0Battaglia)
+			return shawFThermalFunc(alpha0Shaw, nntShaw), nil
 		case PlusSigmaCurve:
-			return makeFTh(a0pBattaglia, xc0pBattaglia, alpha0pBattaglia)
+			return shawFThermalFunc(alpha0Shaw + alpha0SigShaw,
+				nntShaw + nntSigShaw), nil
 		case MinusSigmaCurve:
-			return makeFTh(a0mBattaglia, xc0mBattaglia, alpha0mBattaglia)
+			return shawFThermalFunc(alpha0Shaw - alpha0SigShaw,
+				nntShaw - nntSigShaw), nil
 		}
+		return nil, &EnumError{Type: "FThermalCurveType", Value: int(ftct)}
 
-		panic("Unrecognized FThermalCurveType for Battaglia2013")
+	case Nelson2014:
+		switch ftct {
+		case MeanCurve:
+			// Fit in units of R200m.
+			return fThermalFit{
+				f: func(h *Halo, r float64) float64 {
+					x := r / h.A200.R
+					return aNelson14 * (1 + math.Exp(
+						-math.Pow(x/bNelson14, gammaNelson14)))
+				},
+				dLnF: func(h *Halo, r float64) float64 {
+					u := math.Pow(r/h.A200.R/bNelson14, gammaNelson14)
+					e := math.Exp(-u)
+					return -gammaNelson14 * u * e / (1 + e)
+				},
+				d2LnF: func(h *Halo, r float64) float64 {
+					u := math.Pow(r/h.A200.R/bNelson14, gammaNelson14)
+					e := math.Exp(-u)
+					return -gammaNelson14 * gammaNelson14 * u * e *
+						(1 + e - u) / ((1 + e) * (1 + e))
+				},
+			}, nil
+		}
+		return nil, &EnumError{
+			Type: "FThermalCurveType", Value: int(ftct),
+			Context: "Nelson2014",
+		}
+
+	case ShiKomatsu2014:
+		switch ftct {
+		case MeanCurve:
+			return ShiKomatsuFThermalFunc(0, etaShiKomatsu, betaShiKomatsu)
+		}
+		return nil, &EnumError{
+			Type: "FThermalCurveType", Value: int(ftct),
+			Context: "ShiKomatsu2014",
+		}
 	}
-	panic("Unrecognized FThermalType")
+	return nil, &EnumError{Type: "FThermalType", Value: int(ftt)}
 }
 
-func BetaBiasFunc(fTh RadialFuncType) RadialFuncType {
+// battagliaFThermalFunc returns the Battaglia et al. (2013) relation,
+// f_th = A (1 + (x/xc)^2)^-alpha with x = r/R500c, where A, xc, and alpha
+// scale as power laws in (1 + z) and M500c from A0, xc0, and alpha0.
+func battagliaFThermalFunc(A0, xc0, alpha0 float64) FThermalModel {
+	params := func(h *Halo) (A, xc, alpha float64) {
+		mFrac := h.C500.M / pivotMassBattaglia
+
+		xc = xc0 * math.Pow(1.0+h.Z, xcNzBattaglia) *
+			math.Pow(mFrac, xcNmBattaglia)
+		alpha = alpha0 * math.Pow(1.0+h.Z, alphaNzBattaglia) *
+			math.Pow(mFrac, alphaNmBattaglia)
+		A = A0 * math.Pow(1.0+h.Z, aNzBattaglia) *
+			math.Pow(mFrac, aNmBattaglia)
+		return A, xc, alpha
+	}
+
+	return fThermalFit{
+		f: func(h *Halo, r float64) float64 {
+			A, xc, alpha := params(h)
+			x := r / h.C500.R
+			return A * math.Pow(1.0+math.Pow(x/xc, 2.0), -alpha)
+		},
+		dLnF: func(h *Halo, r float64) float64 {
+			_, xc, alpha := params(h)
+			u := math.Pow(r/h.C500.R/xc, 2.0)
+			return -2 * alpha * u / (1 + u)
+		},
+		d2LnF: func(h *Halo, r float64) float64 {
+			_, xc, alpha := params(h)
+			u := math.Pow(r/h.C500.R/xc, 2.0)
+			return -4 * alpha * u / ((1 + u) * (1 + u))
+		},
+	}
+}
+
+// nelsonFThermalFunc returns the Nelson et al. (2012) relation,
+// f_th = 1 - alpha (r/R500c)^n.
+func nelsonFThermalFunc(alpha, n float64) FThermalModel {
+	fNT := func(h *Halo, r float64) float64 {
+		return alpha * math.Pow(r / h.C500.R, n)
+	}
+	return powerLawFit(fNT, n)
+}
+
+// shawFThermalFunc returns the Shaw et al. (2010) relation,
+// f_nt = alpha(z) (r/R500c)^nnt (M200c/3e14)^0.2, with the amplitude
+// alpha(z) = alpha0 (1 + z)^0.5 capped at high redshift as in Shaw et al.
+// (2012) so that f_th stays positive within 4 R500c.
+func shawFThermalFunc(alpha0, nnt float64) FThermalModel {
+	fMax := math.Pow(xMaxShaw, -nnt) / alpha0
+	fNT := func(h *Halo, r float64) float64 {
+		x := r / h.C500.R
+		alpha := alpha0 * math.Min(math.Pow(1 + h.Z, betaShaw),
+			(fMax - 1) * math.Tanh(betaShaw * h.Z) + 1)
+		return alpha * math.Pow(x, nnt) *
+			math.Pow(h.C200.M/pivotMassShaw, nmShaw)
+	}
+	return powerLawFit(fNT, nnt)
+}
+
+// ShiKomatsuFThermalFunc returns the Shi & Komatsu (2014) model for f_th in
+// the limit where the non-thermal pressure fraction is in equilibrium
+// between turbulent injection and dissipation:
+//
+//     f_nt = eta t_d g / (1 + t_d g)
+//
+// Here g = (2/3) Gamma H(z) is the growth rate of the halo's velocity
+// dispersion, Gamma = d ln M / d ln a is its mass accretion rate, and
+// t_d = (beta / 2) t_dyn(r) is the turbulence dissipation time, where
+// t_dyn = 2 pi sqrt(r^3 / (G M(< r))). Shi & Komatsu's fiducial values are
+// eta = 0.7 and beta = 1. If accretionRate is zero, Gamma is found from the
+// mean accretion rate of halos with mass h.A200.M given by Fakhouri, Ma, &
+// Boylan-Kolchin (2010).
+func ShiKomatsuFThermalFunc(accretionRate, eta, beta float64) (FThermalModel, error) {
+	if accretionRate < 0 {
+		return nil, &ParameterError{
+			"ShiKomatsu2014", "accretionRate", accretionRate,
+			"accretionRate >= 0",
+		}
+	} else if eta < 0 || eta > 1 {
+		return nil, &ParameterError{
+			"ShiKomatsu2014", "eta", eta, "0 <= eta <= 1",
+		}
+	} else if beta <= 0 {
+		return nil, &ParameterError{
+			"ShiKomatsu2014", "beta", beta, "beta > 0",
+		}
+	}
+
+	// w = t_d g
+	w := func(h *Halo, r float64) float64 {
+		H := h.cosmology.HubbleFrac(h.Z) * cosmo.H0Mks * h.cosmology.H100
+
+		gamma := accretionRate
+		if gamma == 0 {
+			gamma = meanAccretionRate(h.cosmology, h.A200.M, h.Z)
+		}
+		g := 2.0 / 3.0 * gamma * H
+
+		rMks := r * cosmo.MpcMks
+		mMks := h.MassEnclosed(Corrected, r) * cosmo.MSunMks
+		tDyn := 2 * math.Pi * math.Sqrt(rMks*rMks*rMks/(cosmo.GMks*mMks))
+		tD := beta / 2 * tDyn
+
+		return tD * g
+	}
+
+	// d ln M / d ln r
+	mu := func(h *Halo, r float64) float64 {
+		return 4 * math.Pi * r * r * r * h.Rho(Corrected, r) /
+			h.MassEnclosed(Corrected, r)
+	}
+
+	return fThermalFit{
+		f: func(h *Halo, r float64) float64 {
+			wr := w(h, r)
+			return 1 - eta*wr/(1+wr)
+		},
+		dLnF: func(h *Halo, r float64) float64 {
+			wr := w(h, r)
+			dLnWdLnR := (3 - mu(h, r)) / 2
+			dFNT := eta * wr / ((1 + wr) * (1 + wr)) * dLnWdLnR
+			return -dFNT / (1 - eta*wr/(1+wr))
+		},
+		d2LnF: func(h *Halo, r float64) float64 {
+			wr, m := w(h, r), mu(h, r)
+			// L = d ln w / d ln r and dL = d L / d ln r.
+			L := (3 - m) / 2
+			dL := -m * (3 + h.model.dLnRho(r) - m) / 2
+
+			f := 1 - eta*wr/(1+wr)
+			dFNT := eta * wr * L / ((1 + wr) * (1 + wr))
+			d2FNT := eta * wr * ((L*L+dL)*(1+wr) - 2*wr*L*L) /
+				((1 + wr) * (1 + wr) * (1 + wr))
+			return -(d2FNT*f + dFNT*dFNT) / (f * f)
+		},
+	}, nil
+}
+
+// meanAccretionRate returns the mean value of d ln M / d ln a for halos of
+// mass m at redshift z in the cosmology c (Fakhouri, Ma, & Boylan-Kolchin,
+// 2010).
+func meanAccretionRate(c cosmo.Cosmology, m, z float64) float64 {
+	H0 := cosmo.H0Mks * c.H100
+	dlnMdt := accretionAmpFakhouri / accretionPivotFakhouri / yearMks *
+		math.Pow(m/accretionPivotFakhouri, accretionNmFakhouri - 1) *
+		(1 + accretionNzFakhouri*z) * c.HubbleFrac(z)
+	return dlnMdt / (H0 * c.HubbleFrac(z))
+}
+
+// BetaBiasFunc returns a function which calculates d ln f_th / d ln r. It is
+// computed analytically if fTh is an FThermalSlopeModel and numerically
+// otherwise. For the built-in models the two differ by less than 1e-9 in the
+// slope between 0.05 and 2 R500c, and halos constructed with either have
+// values of M500cBias and C500.M which differ by less than 1e-9 (see
+// TestAnalyticSlopes and TestNumericSlopes), so the choice only affects
+// speed.
+func BetaBiasFunc(fTh FThermalModel) RadialFuncType {
+	if sm, ok := fTh.(FThermalSlopeModel); ok {
+		return sm.DLnFThermalDLnR
+	}
 	return func(h *Halo, r float64) float64 {
-		fThR := func(r float64) float64 { return fTh(h, r) }
+		fThR := func(r float64) float64 { return fTh.FThermal(h, r) }
 		return num.Derivative(fThR, r)(r) * r / fThR(r)
 	}
 }
 
-func AlphaBiasFunc(fTh RadialFuncType) RadialFuncType {
+// AlphaBiasFunc returns a function which calculates d ln P_th / d ln r for
+// the halo's pressure profile. It is computed analytically if the profile
+// is a PressureDerivativeProfile, as all of the built-in profiles are, and
+// numerically otherwise, with the same tolerance as BetaBiasFunc.
+func AlphaBiasFunc(fTh FThermalModel) RadialFuncType {
 	return func(h *Halo, r float64) float64 {
-		return h.DPdr(ThermalPressure, h.ppt, ElectronPressure, r) * r /
-			h.Pressure(ThermalPressure, h.ppt, ElectronPressure, r) *
+		return h.DPdr(ThermalPressure, h.pp, ElectronPressure, r) * r /
+			h.Pressure(ThermalPressure, h.pp, ElectronPressure, r) *
 			cosmo.MpcMks
 	}
 }
 
 // BFracFunc returns a function which calculates b(r) for a given halo.
 // Here b(r) = mTrue(< r) / mBias(< r).
-func BFracFunc(fTh RadialFuncType, corr num.CurveCorrectionType) RadialFuncType {
+func BFracFunc(fTh FThermalModel, corr num.CurveCorrectionType) RadialFuncType {
 	return func(h *Halo, r float64) float64 {
 		fThR := func(r float64) float64 {
-			return fTh(h, r)
+			return fTh.FThermal(h, r)
 		}
 
 		switch corr {
@@ -0,0 +1,239 @@
+package halo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+const (
+	// Step in ln(r) used by lnRDerivative.
+	slopeStep = 3e-4
+	// Largest allowed difference between the analytic quantities and the
+	// finite difference estimates of them.
+	slopeTolerance = 1e-9
+	// Largest allowed relative difference between the masses of halos
+	// constructed with analytic and numerical slopes. See BetaBiasFunc.
+	numericSlopeTolerance = 1e-9
+)
+
+// lnRDerivative returns d f / d ln r at r, found with a five-point central
+// difference in ln(r). Its truncation error is of order slopeStep^4.
+func lnRDerivative(f func(float64) float64, r float64) float64 {
+	g := func(i float64) float64 { return f(r * math.Exp(i*slopeStep)) }
+	return (g(-2) - 8*g(-1) + 8*g(1) - g(2)) / (12 * slopeStep)
+}
+
+// logSlope returns d ln f / d ln r at r. See lnRDerivative.
+func logSlope(f func(float64) float64, r float64) float64 {
+	return lnRDerivative(func(r float64) float64 { return math.Log(f(r)) }, r)
+}
+
+type namedFThermal struct {
+	name string
+	fTh  FThermalModel
+}
+
+// builtinFThermals returns every built-in f_th model.
+func builtinFThermals(t *testing.T) []namedFThermal {
+	types := []struct {
+		name string
+		ftt  FThermalType
+	}{
+		{"Battaglia2012", Battaglia2012}, {"Battaglia2013", Battaglia2013},
+		{"Nelson2012", Nelson2012}, {"Shaw2010", Shaw2010},
+		{"Nelson2014", Nelson2014}, {"ShiKomatsu2014", ShiKomatsu2014},
+	}
+	curves := []FThermalCurveType{MeanCurve, PlusSigmaCurve, MinusSigmaCurve}
+
+	models := []namedFThermal{}
+	for _, typ := range types {
+		for _, ftct := range curves {
+			fTh, err := FThermalFunc(typ.ftt, ftct)
+			if err != nil {
+				continue
+			}
+			models = append(models, namedFThermal{typ.name, fTh})
+		}
+	}
+
+	fTh, err := ShiKomatsuFThermalFunc(2, etaShiKomatsu, betaShiKomatsu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(models, namedFThermal{"ShiKomatsu2014 Gamma=2", fTh})
+}
+
+// checkSlopes compares h's analytic BetaBias, AlphaBias, BFrac, biased
+// density, and effective pressure derivative, as well as the curvatures
+// they are computed from, with finite difference estimates at several radii.
+func checkSlopes(t *testing.T, name string, h *Halo) {
+	thermal := func(r float64) float64 {
+		return h.Pressure(ThermalPressure, h.pp, ElectronPressure, r)
+	}
+	effective := func(r float64) float64 {
+		return h.Pressure(EffectivePressure, h.pp, AllPressure, r)
+	}
+	if h.dLnBFrac == nil {
+		t.Fatalf("%s: d ln BFrac / d ln r is not analytic", name)
+	}
+
+	for _, x := range []float64{0.05, 0.2, 0.5, 1, 2} {
+		r := x * h.C500.R
+		beta, alpha := logSlope(h.FThermal, r), logSlope(thermal, r)
+		bFrac := (1 - beta/alpha) / h.FThermal(r)
+
+		if d := math.Abs(h.BetaBias(r) - beta); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: BetaBias = %.12g, numerical "+
+				"value = %.12g", name, x, h.BetaBias(r), beta)
+		}
+		if d := math.Abs(h.AlphaBias(r) - alpha); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: AlphaBias = %.12g, numerical "+
+				"value = %.12g", name, x, h.AlphaBias(r), alpha)
+		}
+		if d := math.Abs(h.BFrac(r)/bFrac - 1); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: BFrac = %.12g, numerical "+
+				"value = %.12g", name, x, h.BFrac(r), bFrac)
+		}
+
+		dBeta := lnRDerivative(h.BetaBias, r)
+		dBetaA := h.fTh.(FThermalCurvatureModel).D2LnFThermalDLnR2(h, r)
+		if d := math.Abs(dBetaA - dBeta); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: d BetaBias / d ln r = %.12g, "+
+				"numerical value = %.12g", name, x, dBetaA, dBeta)
+		}
+		dAlpha := lnRDerivative(h.AlphaBias, r)
+		dAlphaA := h.pp.(PressureCurvatureProfile).D2LnElectronPressureDLnR2(
+			h.pressureScale(ThermalPressure, h.pp), r)
+		if d := math.Abs(dAlphaA - dAlpha); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: d AlphaBias / d ln r = %.12g, "+
+				"numerical value = %.12g", name, x, dAlphaA, dAlpha)
+		}
+		if dLnB := logSlope(h.BFrac, r); math.Abs(h.dLnBFrac(r)-dLnB) > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: d ln BFrac / d ln r = %.12g, "+
+				"numerical value = %.12g", name, x, h.dLnBFrac(r), dLnB)
+		}
+
+		// M_bias = M_true / b. M_true is differentiated analytically because
+		// the DK14 mass is a tabulated integral whose derivative only
+		// matches rho to about 1e-9.
+		invB := func(r float64) float64 { return 1 / h.BFrac(r) }
+		rho := h.Rho(Corrected, r)/h.BFrac(r) + h.MassEnclosed(Corrected, r)*
+			lnRDerivative(invB, r)/(4*math.Pi*r*r*r)
+		if d := math.Abs(h.Rho(Biased, r)/rho - 1); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: biased Rho = %.12g, numerical "+
+				"value = %.12g", name, x, h.Rho(Biased, r), rho)
+		}
+		dPdr := lnRDerivative(effective, r) / r / cosmo.MpcMks
+		dPdrA := h.DPdr(EffectivePressure, h.pp, AllPressure, r)
+		if d := math.Abs(dPdrA/dPdr - 1); d > slopeTolerance {
+			t.Errorf("%s at r = %g R500c: effective DPdr = %.12g, "+
+				"numerical value = %.12g", name, x, dPdrA, dPdr)
+		}
+	}
+}
+
+func TestAnalyticSlopes(t *testing.T) {
+	c := cosmo.Fiducial
+	for _, z := range []float64{0, 1} {
+		cFunc, err := ConcentrationFunc(c, Bhattacharya2013, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range []float64{1e14, 1e15} {
+			for _, model := range builtinFThermals(t) {
+				h, err := New(c, model.fTh, BattagliaAGN2012, MassProfile{},
+					cFunc, Corrected, m, z)
+				if err != nil {
+					t.Errorf("%s, M500c = %g, z = %g: %s", model.name, m, z, err)
+					continue
+				}
+				checkSlopes(t, model.name, h)
+			}
+
+			fTh, _ := FThermalFunc(Battaglia2013, MeanCurve)
+			for ppt := PressureProfileType(0); ppt < pressureProfileTypeCount; ppt++ {
+				h, err := New(c, fTh, ppt, MassProfile{}, cFunc, Corrected, m, z)
+				if err != nil {
+					t.Errorf("%s, M500c = %g, z = %g: %s", ppt, m, z, err)
+					continue
+				}
+				checkSlopes(t, ppt.String(), h)
+			}
+
+			mps := []MassProfile{
+				{Type: Einasto, Alpha: 0.18}, {Type: Hernquist},
+				{Type: GeneralizedNFW, Alpha: 1.5, Beta: 4, Gamma: 0.5},
+				{Type: DK14},
+			}
+			for _, mp := range mps {
+				h, err := New(c, fTh, Arnaud2009, mp, cFunc, Corrected, m, z)
+				if err != nil {
+					t.Errorf("MassProfileType %d, M500c = %g, z = %g: %s",
+						mp.Type, m, z, err)
+					continue
+				}
+				checkSlopes(t, fmt.Sprintf("MassProfileType %d", mp.Type), h)
+			}
+		}
+	}
+}
+
+// numericFThermal and numericPressure hide the analytic derivatives of the
+// models they wrap, so halos built from them use finite differences.
+type numericFThermal struct{ fTh FThermalModel }
+type numericPressure struct{ PressureProfile }
+
+func (f numericFThermal) FThermal(h *Halo, r float64) float64 {
+	return f.fTh.FThermal(h, r)
+}
+
+// TestNumericSlopes checks that halos built with numerical BetaBias and
+// AlphaBias agree with those built with the analytic ones to within the
+// tolerance given in the BetaBiasFunc documentation.
+func TestNumericSlopes(t *testing.T) {
+	c := cosmo.Fiducial
+	for _, z := range []float64{0, 1} {
+		cFunc, err := ConcentrationFunc(c, Bhattacharya2013, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range []float64{1e14, 1e15} {
+			for _, model := range builtinFThermals(t) {
+				for ppt := PressureProfileType(0); ppt < pressureProfileTypeCount; ppt++ {
+					name := fmt.Sprintf("%s, %s, M500c = %g, z = %g",
+						model.name, ppt, m, z)
+					h, err := New(c, model.fTh, ppt, MassProfile{}, cFunc,
+						Corrected, m, z)
+					if err != nil {
+						t.Errorf("%s: %s", name, err)
+						continue
+					}
+					hn, err := New(c, numericFThermal{model.fTh},
+						numericPressure{ppt}, MassProfile{}, cFunc,
+						Corrected, m, z)
+					if err != nil {
+						t.Errorf("%s: %s", name, err)
+						continue
+					}
+					if hn.dLnBFrac != nil {
+						t.Fatalf("%s: wrapped models are analytic", name)
+					}
+
+					if d := math.Abs(hn.M500cBias/h.M500cBias - 1); d > numericSlopeTolerance {
+						t.Errorf("%s: numerical M500cBias = %.12g, analytic "+
+							"value = %.12g", name, hn.M500cBias, h.M500cBias)
+					}
+					if d := math.Abs(hn.C500.M/h.C500.M - 1); d > numericSlopeTolerance {
+						t.Errorf("%s: numerical C500.M = %.12g, analytic "+
+							"value = %.12g", name, hn.C500.M, h.C500.M)
+					}
+				}
+			}
+		}
+	}
+}
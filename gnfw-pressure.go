@@ -0,0 +1,98 @@
+package halo
+
+import (
+	"math"
+)
+
+const (
+	gnfwPivotM500H = 3e14
+	gnfwA0kev      = 1.65e-3
+)
+
+// GNFWPressure is a generalized NFW pressure profile (Nagai, Kravtsov, &
+// Vikhlinin, 2007) whose parameters are set at runtime:
+//
+//     P(r) = P500 P0 / (y^Gamma (1 + y^Alpha)^((Beta - Gamma)/Alpha))
+//
+// where y = C500 r / R500c. P500 follows the convention of Arnaud et al.
+// (2010) used by the built-in profiles:
+//
+//     P500 = 1.65e-3 E(z)^EzExponent (M500c / 3e14 h70^-1 M_sun)^MassExponent h70^2 keV cm^-3
+//
+// so the self-similar model has MassExponent = 2/3 and EzExponent = 8/3.
+// Planck2012 corresponds to P0 = 6.41, C500 = 1.81, Alpha = 1.33,
+// Beta = 4.13, Gamma = 0.31, MassExponent = 2/3 + 0.12, EzExponent = 8/3,
+// and BiasedMass = true.
+//
+// BiasedMass should be set if the parameters were fit against hydrostatic
+// mass estimates. Every field is used as given: there are no defaults.
+type GNFWPressure struct {
+	P0, C500                 float64
+	Alpha, Beta, Gamma       float64
+	MassExponent, EzExponent float64
+	BiasedMass               bool
+}
+
+// Typechecking
+var _ PressureCurvatureProfile = GNFWPressure{}
+
+// Validate returns an error if the parameters of p cannot describe a
+// pressure profile.
+func (p GNFWPressure) Validate() error {
+	if p.P0 <= 0 {
+		return &ParameterError{"GNFWPressure", "P0", p.P0, "P0 > 0"}
+	} else if p.C500 <= 0 {
+		return &ParameterError{"GNFWPressure", "C500", p.C500, "C500 > 0"}
+	} else if p.Alpha <= 0 {
+		return &ParameterError{"GNFWPressure", "Alpha", p.Alpha, "Alpha > 0"}
+	}
+	return nil
+}
+
+// RequiresBiasedMass returns p.BiasedMass.
+func (p GNFWPressure) RequiresBiasedMass() bool { return p.BiasedMass }
+
+// p500 returns the characteristic pressure of a halo in Pa.
+func (p GNFWPressure) p500(s PressureScale) float64 {
+	h70 := s.Cosmology.H70()
+	mFrac := s.M500c / (gnfwPivotM500H / h70)
+	return gnfwA0kev * math.Pow(mFrac, p.MassExponent) *
+		math.Pow(s.Cosmology.HubbleFrac(s.Z), p.EzExponent) *
+		h70 * h70 * kevToPascal
+}
+
+// ElectronPressure returns the electron pressure in Pa at a distance r from
+// the center of a halo with the given scale.
+func (p GNFWPressure) ElectronPressure(s PressureScale, r float64) float64 {
+	y := p.C500 * r / s.R500c
+	return p.p500(s) * p.P0 / (math.Pow(y, p.Gamma) *
+		math.Pow(1+math.Pow(y, p.Alpha), (p.Beta-p.Gamma)/p.Alpha))
+}
+
+// DElectronPressureDr returns the derivative of ElectronPressure with respect
+// to r in Pa / Mpc.
+func (p GNFWPressure) DElectronPressureDr(s PressureScale, r float64) float64 {
+	return p.ElectronPressure(s, r) * p.dLnPdLnR(s, r) / r
+}
+
+// D2LnElectronPressureDLnR2 returns d^2 ln P_e / d ln r^2.
+func (p GNFWPressure) D2LnElectronPressureDLnR2(s PressureScale, r float64) float64 {
+	return gnfwLogCurvature(p.C500*r/s.R500c, p.Alpha, p.Beta, p.Gamma)
+}
+
+func (p GNFWPressure) dLnPdLnR(s PressureScale, r float64) float64 {
+	return gnfwLogSlope(p.C500*r/s.R500c, p.Alpha, p.Beta, p.Gamma)
+}
+
+// gnfwLogSlope returns d ln P / d ln y for a GNFW profile evaluated at y.
+func gnfwLogSlope(y, alpha, beta, gamma float64) float64 {
+	ya := math.Pow(y, alpha)
+	return -gamma - (beta-gamma)*ya/(1+ya)
+}
+
+// gnfwLogCurvature returns d^2 ln P / d ln y^2 for a GNFW profile evaluated
+// at y.
+func gnfwLogCurvature(y, alpha, beta, gamma float64) float64 {
+	ya := math.Pow(y, alpha)
+	return -(beta - gamma) * alpha * ya / ((1 + ya) * (1 + ya))
+}
@@ -4,7 +4,6 @@ describing the physical state of dark matter halos. */
 package halo
 
 import (
-	"fmt"
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo/cosmo"
@@ -23,26 +22,27 @@ const (
 	Corrected
 )
 
-type DensityType int
-
-const (
-	A200 DensityType = iota
-	C200
-	C500
-)
-
+// MassProfileType is a flag corresponding to the functional form of a
+// halo's true density profile. See MassProfile for the parameters of each
+// form.
 type MassProfileType int
 
 const (
 	NFW MassProfileType = iota
+	Einasto
+	Hernquist
+	GeneralizedNFW
+	DK14
 )
 
+// DensityInfo describes a halo's mass, radius, and concentration under a
+// particular overdensity definition.
 type DensityInfo struct {
 	M, R, C float64
 }
 
 type halo interface {
-	DensityInfo(d DensityType) *DensityInfo
+	DensityInfo(bt BiasType, d DensityType) (DensityInfo, error)
 	Redshift() float64
 	PivotRadius() float64
 	MinR() float64
@@ -52,21 +52,29 @@ type halo interface {
 	Rho(bt BiasType, r float64) float64
 	OverdensityRadius(bt BiasType, rho float64) float64
 
-	GasEnclosed(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, r float64) float64
-	RhoGas(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, r float64) float64
+	GasEnclosed(bt BiasType, pbt PressureBiasType, pp PressureProfile, r float64) float64
+	RhoGas(bt BiasType, pbt PressureBiasType, pp PressureProfile, r float64) float64
 
-	Pressure(pbt PressureBiasType, ppt PressureProfileType, pt PressurePopulationType, r float64) float64
-	DPdr(pbt PressureBiasType, ppt PressureProfileType, pt PressurePopulationType, r float64) float64
-	ThompsonY(pbt PressureBiasType, ppt PressureProfileType, r float64) float64
+	Pressure(pbt PressureBiasType, pp PressureProfile, pt PressurePopulationType, r float64) float64
+	DPdr(pbt PressureBiasType, pp PressureProfile, pt PressurePopulationType, r float64) float64
+	ThompsonY(pbt PressureBiasType, pp PressureProfile, r float64) float64
 
-	EWTemperature(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, rMax float64) float64
+	EWTemperature(bt BiasType, pbt PressureBiasType, pp PressureProfile, rMax float64) float64
 }
 
+// Halo describes the physical state of a dark matter halo and its gas.
+//
+// A Halo is immutable once it has been returned by one of its constructors,
+// and all of its methods are safe for concurrent use by multiple goroutines,
+// provided that the FThermalModel and PressureProfile it was constructed
+// with are. The exported fields must be treated as read-only.
 type Halo struct {
 	A200, C200, C500 DensityInfo
 	Z                float64
 	Rs               float64
 
+	cosmology cosmo.Cosmology
+
 	M500cBias, R500cBias float64
 
 	FThermal num.Func1D
@@ -74,8 +82,25 @@ type Halo struct {
 	AlphaBias num.Func1D
 	BetaBias num.Func1D
 
-	ppt PressureProfileType
-	mpt MassProfileType
+	pp  PressureProfile
+	fTh FThermalModel
+	// d ln BFrac / d ln r. Nil unless fTh is an FThermalCurvatureModel and
+	// pp is a PressureCurvatureProfile.
+	dLnBFrac num.Func1D
+
+	// diagnostics records the searches which determined h's parameters.
+	// It is not updated for trial halos.
+	diagnostics []RootDiagnostics
+	trial       bool
+
+	mp    MassProfile
+	shape profileShape
+	model densityModel
+	// Non-nil if the shape of h depends on its mass.
+	einastoAlpha num.Func1D
+	nu           num.Func1D
+
+	densityCache densityCache
 }
 
 // typechecking
@@ -95,20 +120,6 @@ func haloDensity(r, m float64) float64 {
 	return m / (4.0 * math.Pi / 3.0 * r * r * r)
 }
 
-// DensityInfo returns the DensityInfo struct associated with the given
-// DensityType.
-func (h *Halo) DensityInfo(d DensityType) *DensityInfo {
-	switch d {
-	case A200:
-		return &h.A200
-	case C200:
-		return &h.C200
-	case C500:
-		return &h.C500
-	}
-	panic("DensityInfo given invalid DensityType")
-}
-
 // Redshift returns the redshift of h.
 func (h *Halo) Redshift() float64 { return h.Z }
 
@@ -119,139 +130,476 @@ func (h *Halo) PivotRadius() float64 { return h.Rs }
 // numerical integration. Values less than MinR are not garuanteed to be
 func (h *Halo) MinR() float64 { return 0.0001 * h.Rs }
 
-// MaxR
-func (h *Halo) MaxR() float64 { return 2 * h.C200.R }
-
-func initSearching(h *Halo, cFunc num.Func1D, m, r float64) {
-	rho200c := cosmo.RhoCritical(h.Z) * 200
-
-	m200cToR := func(m200c float64) float64 {
-		h.C200.R = haloRadius(m200c, rho200c)
-		h.C200.C = cFunc(m200c)
-		h.C200.M = m200c
-
-		h.Rs = h.C200.R / h.C200.C
-
-		return h.MassEnclosed(Corrected, r)
+// MaxR gives the maximum radius of the halo for the purposes of consistent
+// numerical integration. For DK14 halos, which model the infall region
+// beyond the splashback radius, this is the pivot radius of the infall term,
+// 5 R200m. Otherwise it is 2 R200c.
+func (h *Halo) MaxR() float64 {
+	if h.mp.Type == DK14 {
+		return dk14OuterPivot * h.A200.R
 	}
+	return 2 * h.C200.R
+}
 
-	m200c := num.FindEqualConst(m200cToR, m, m, m)
+// setC200 modifies h so that its 200c DensityInfo, h.Rs, and the shape of
+// its profile correspond to a halo with the given m200c. The shape of the
+// profile is updated before cFunc is called. An error is returned if the
+// halo's densityModel cannot be constructed.
+func setC200(h *Halo, cFunc num.Func1D, m200c, rho200c float64) error {
+	if h.einastoAlpha != nil {
+		h.shape = newProfileShape(h.mp, h.einastoAlpha(m200c))
+	}
 
 	h.C200.R = haloRadius(m200c, rho200c)
 	h.C200.C = cFunc(m200c)
 	h.C200.M = m200c
 
 	h.Rs = h.C200.R / h.C200.C
+
+	var err error
+	h.model, err = newDensityModel(h.mp, h.shape, h.C200, h.Rs, h.cosmology,
+		h.Z, h.nu)
+	return err
 }
 
-// initDensityInfo modifies h so that its various DensityInfo fields
-// correspond to a halo with the given m500c. Also updates h.Rs and h.rhoS
-func initDensityInfo(h *Halo, cFunc num.Func1D, m, r float64) {
-	rho500c := cosmo.RhoCritical(h.Z) * 500
-	rho200a := cosmo.RhoAverage(h.Z) * 200
+// initSearching modifies h so that its true profile encloses a mass m within
+// the radius r. Trial profiles are set up on a clone of h, so h is only
+// modified once the search has succeeded.
+func initSearching(h *Halo, cFunc num.Func1D, m, r float64) error {
+	rho200c := h.cosmology.RhoCritical(h.Z) * 200
+
+	trial := h.clone()
+	var modelErr error
+	m200cToR := func(m200c float64) float64 {
+		if err := setC200(trial, cFunc, m200c, rho200c); err != nil {
+			if modelErr == nil {
+				modelErr = err
+			}
+			return math.NaN()
+		}
+		return trial.MassEnclosed(Corrected, r)
+	}
+
+	m200c, diag, err := findEqualConst("m200c", m200cToR, m, m)
+	if modelErr != nil {
+		return modelErr
+	} else if err != nil {
+		return err
+	}
+	h.record(diag)
+
+	return setC200(h, cFunc, m200c, rho200c)
+}
+
+// initDensityInfo modifies h so that its various true DensityInfo fields
+// correspond to a halo which encloses a true mass m within the radius r.
+// Also updates h.Rs and the halo's profile.
+func initDensityInfo(h *Halo, cFunc num.Func1D, m, r float64) error {
+	rho500c := C500.Density(h.cosmology, h.Z)
 
 	// This sets c200 for us.
-	initSearching(h, cFunc, m, r)
+	if err := initSearching(h, cFunc, m, r); err != nil {
+		return err
+	}
 
-	h.A200.R = h.OverdensityRadius(Corrected, rho200a)
-	h.A200.C = h.A200.R / h.Rs
-	h.A200.M = haloMass(h.A200.R, rho200a)
+	if err := initA200(h); err != nil {
+		return err
+	}
 
-	h.C500.R = h.OverdensityRadius(Corrected, rho500c)
+	var err error
+	h.C500.R, err = h.initOverdensityRadius("R500c", Corrected, rho500c)
+	if err != nil {
+		return err
+	}
 	h.C500.C = h.C500.R / h.Rs
 	h.C500.M = haloMass(h.C500.R, rho500c)
 
-	// This will already be set if the biased mass is required.
-	if !h.ppt.RequiresBiasedMass() {
-		h.R500cBias = h.OverdensityRadius(Biased, rho500c)
+	return nil
+}
+
+// initA200 sets h's true 200m DensityInfo from its current profile.
+func initA200(h *Halo) error {
+	rho200a := A200.Density(h.cosmology, h.Z)
+
+	var err error
+	h.A200.R, err = h.initOverdensityRadius("R200m", Corrected, rho200a)
+	if err != nil {
+		return err
+	}
+	h.A200.C = h.A200.R / h.Rs
+	h.A200.M = haloMass(h.A200.R, rho200a)
+	return nil
+}
+
+// initBias500c sets h.R500cBias and h.M500cBias. h's true profile and true
+// 500c DensityInfo must already be initialized. If h's pressure profile
+// depends on the biased mass, R500cBias must be solved for self-consistently.
+func initBias500c(h *Halo) error {
+	rho500c := C500.Density(h.cosmology, h.Z)
+
+	if h.pp.RequiresBiasedMass() {
+		var searchErr error
+
+		trial := h.clone()
+		r500cBiasLhs := func(r500cBias float64) float64 { return r500cBias }
+		r500cBiasRhs := func(r500cBias float64) float64 {
+			trial.R500cBias = r500cBias
+			trial.M500cBias = haloMass(r500cBias, rho500c)
+			r, err := trial.overdensityRadius(Biased, rho500c)
+			if err != nil {
+				searchErr = err
+				return math.NaN()
+			}
+			return r
+		}
+
+		r500cBias, diag, err := findEqual("self-consistent R500cBias",
+			r500cBiasLhs, r500cBiasRhs, h.C500.R/2, h.C500.R)
+		if searchErr != nil {
+			return searchErr
+		} else if err != nil {
+			return err
+		}
+		h.record(diag)
+		h.R500cBias = r500cBias
 		h.M500cBias = haloMass(h.R500cBias, rho500c)
 	}
+
+	var err error
+	h.R500cBias, err = h.initOverdensityRadius("R500cBias", Biased, rho500c)
+	if err != nil {
+		return err
+	}
+	h.M500cBias = haloMass(h.R500cBias, rho500c)
+	return nil
 }
 
-func initBiasedHalo(h *Halo, cFunc num.Func1D, m500cBias float64) {
-	rho500c := 500 * cosmo.RhoCritical(h.Z)
+func initBiasedHalo(h *Halo, cFunc num.Func1D, d DensityType, mBias float64) error {
+	rho500c := C500.Density(h.cosmology, h.Z)
+	rBias := haloRadius(mBias, d.Density(h.cosmology, h.Z))
+
+	if d == C500 {
+		h.R500cBias = rBias
+		h.M500cBias = mBias
+	}
 
-	h.R500cBias = haloRadius(m500cBias, rho500c)
-	h.M500cBias = m500cBias
+	// Errors from within the search are recorded here and cause the
+	// residual to become NaN, which aborts the outer search.
+	var searchErr error
 
+	trial := h.clone()
 	bFracLhs := func(b float64) float64 { return b }
 	bFracRhs := func(b float64) float64 {
-		initSearching(h, cFunc, b * h.M500cBias, h.R500cBias)
-		h.C500.R = h.OverdensityRadius(Corrected, rho500c)
-		h.C500.M = haloMass(h.C500.R, rho500c)
+		err := initSearching(trial, cFunc, b * mBias, rBias)
+		if err == nil {
+			trial.C500.R, err = trial.initOverdensityRadius("R500c",
+				Corrected, rho500c)
+		}
+		if err == nil {
+			trial.C500.M = haloMass(trial.C500.R, rho500c)
+			// Some f_th relations are functions of R200m.
+			err = initA200(trial)
+		}
+		if err == nil {
+			// Only the pressure profile needs 500c biased quantities.
+			if d != C500 && trial.pp.RequiresBiasedMass() {
+				err = initBias500c(trial)
+			}
+		}
+		if err != nil {
+			searchErr = err
+			return math.NaN()
+		}
 
-		return h.BFrac(h.R500cBias)
+		return trial.BFrac(rBias)
 	}
 
-	// h.BFrac evaluated at r500cBias
-	b := num.FindEqual(bFracLhs, bFracRhs, 1.0, 2.0)
+	// h.BFrac evaluated at the biased radius. Typical biases lie within
+	// [1, 2], but the bracket is widened if needed.
+	b, diag, err := findEqual("M/MBias", bFracLhs, bFracRhs, 1.0, 2.0)
+	if searchErr != nil {
+		return searchErr
+	} else if err != nil {
+		return err
+	}
+	h.record(diag)
 
-	mR500cBias := m500cBias * b
-	initDensityInfo(h, cFunc, mR500cBias, h.R500cBias)
+	if err = initDensityInfo(h, cFunc, mBias * b, rBias); err != nil {
+		return err
+	}
+	if d != C500 {
+		return initBias500c(h)
+	}
+	return nil
 }
 
-func initCorrectedHalo(h *Halo, cFunc num.Func1D, m500cCorrect float64) {
-	rho500c := cosmo.RhoCritical(h.Z) * 500
-
-	h.C500.M = m500cCorrect
-	h.C500.R = haloRadius(h.C500.M, rho500c)
-	initSearching(h, cFunc, h.C500.M, h.C500.R)
-
-	// initDensityInfo may require that r500cBias and m500cBias be initialized.
-	if h.ppt.RequiresBiasedMass() {
-		// initial guess
-		h.M500cBias = h.C500.M
-		h.R500cBias = h.C500.R
+func initCorrectedHalo(h *Halo, cFunc num.Func1D, d DensityType, m float64) error {
+	r := haloRadius(m, d.Density(h.cosmology, h.Z))
+	if err := initDensityInfo(h, cFunc, m, r); err != nil {
+		return err
+	}
+	return initBias500c(h)
+}
 
-		r500cBiasLhs := func(r500cBias float64) float64 { return r500cBias }
-		r500cBiasRhs := func(r500cBias float64) float64 {
-			h.R500cBias = r500cBias
-			h.M500cBias = haloMass(r500cBias, rho500c)
-			return h.OverdensityRadius(Biased, rho500c)
+// fixedConcentrationFunc returns a function which maps a halo's m200c to the
+// c200c of a halo with the profile shape of h and with a concentration c
+// under the overdensity definition d. If the shape of h depends on its
+// mass, the returned function will use h's current shape.
+func fixedConcentrationFunc(h *Halo, d DensityType, c float64) (num.Func1D, error) {
+	rhoFrom := d.Density(h.cosmology, h.Z)
+	rhoTo := C200.Density(h.cosmology, h.Z)
+
+	if h.einastoAlpha == nil {
+		c200c, err := convertConcentration(h.shape, c, rhoFrom, rhoTo)
+		if err != nil {
+			return nil, err
 		}
-
-		h.R500cBias = num.FindEqual(r500cBiasLhs, r500cBiasRhs,
-			h.C500.R/2, h.C500.R)
-		h.M500cBias = haloRadius(h.R500cBias, rho500c)
+		return func(float64) float64 { return c200c }, nil
 	}
 
-	initDensityInfo(h, cFunc, h.C500.M, h.C500.R)
+	return func(float64) float64 {
+		c200c, err := convertConcentration(h.shape, c, rhoFrom, rhoTo)
+		if err != nil {
+			return math.NaN()
+		}
+		return c200c
+	}, nil
+}
 
-	h.R500cBias = h.OverdensityRadius(Biased, rho500c)
-	h.M500cBias = haloMass(h.R500cBias, rho500c)
+// convertConcentration returns the concentration of a halo with the given
+// profile shape under an overdensity definition with density rhoTo, given
+// its concentration cFrom under a definition with density rhoFrom.
+func convertConcentration(shape profileShape, cFrom, rhoFrom, rhoTo float64) (float64, error) {
+	meanDensity := func(x float64) float64 { return shape.m(x) / (x * x * x) }
+	target := meanDensity(cFrom) * rhoTo / rhoFrom
+	c, _, err := findEqualConst("concentration", meanDensity, target, cFrom)
+	return c, err
 }
 
 // New creates a new Halo instance using the given parameters. If the given
 // mass is the m500c of a biased halo, bt should be set to Biased. If the
-// mass is the true m500c of the halo.
-func New(fTh RadialFuncType, ppt PressureProfileType, cFunc num.Func1D, bt BiasType, m500c, z float64) *Halo {
-	if m500c < MinHaloMass || m500c > MaxHaloMass {
-		panic(fmt.Sprintf("The %.5g is not within halo bounds [%.5g, %.5g]",
-			m500c, MinHaloMass, MaxHaloMass))
+// mass is the true m500c of the halo, bt should be set to Corrected.
+//
+// All cosmology-dependent quantities, including overdensity definitions and
+// pressure normalizations, are computed in the given cosmology.
+//
+// pp may be any PressureProfile, including the built-in
+// PressureProfileTypes and user-defined profiles. mp specifies the shape of
+// the halo's true density profile, and cFunc maps the halo's m200c to its
+// concentration (see MassProfile).
+//
+// Any error encountered during construction is returned as a
+// *ConstructionError which wraps the underlying *MassBoundsError,
+// *EnumError, *ParameterError, *BracketError, or *ConvergenceError. The
+// root-finding searches performed during construction can be inspected with
+// Diagnostics.
+func New(cosmology cosmo.Cosmology, fTh FThermalModel, pp PressureProfile, mp MassProfile, cFunc num.Func1D, bt BiasType, m500c, z float64) (*Halo, error) {
+	return NewFromMass(cosmology, fTh, pp, mp, cFunc, bt, C500, m500c, z)
+}
+
+// NewFromMass creates a new Halo instance in the same way as New, except
+// that m is the mass of the halo under the overdensity definition d.
+func NewFromMass(cosmology cosmo.Cosmology, fTh FThermalModel, pp PressureProfile, mp MassProfile, cFunc num.Func1D, bt BiasType, d DensityType, m, z float64) (*Halo, error) {
+	h, err := newHalo(cosmology, fTh, pp, mp, bt, d, m, z)
+	if err == nil {
+		err = initHalo(h, cFunc, bt, d, m)
+	}
+	if err != nil {
+		return nil, &ConstructionError{bt, d, m, z, err}
+	}
+	return h, nil
+}
+
+// NewFromConcentration creates a new Halo instance with mass m and a fixed
+// concentration c, both measured under the overdensity definition d. If bt
+// is Biased, m is the biased mass of the halo and c is the concentration of
+// its true profile. Otherwise it behaves like NewFromMass.
+//
+// If the shape of the halo's profile depends on its mass (i.e. Einasto and
+// DK14 profiles which use the default Alpha), c is converted to c200c using
+// the profile's shape at each trial m200c. The truncation and infall terms
+// of DK14 profiles are neglected during this conversion.
+func NewFromConcentration(cosmology cosmo.Cosmology, fTh FThermalModel, pp PressureProfile, mp MassProfile, bt BiasType, d DensityType, m, c, z float64) (*Halo, error) {
+	h, err := newHalo(cosmology, fTh, pp, mp, bt, d, m, z)
+	if err == nil && c <= 0 {
+		err = &ParameterError{"NewFromConcentration", "c", c, "c > 0"}
+	}
+	var cFunc num.Func1D
+	if err == nil {
+		cFunc, err = fixedConcentrationFunc(h, d, c)
+	}
+	if err == nil {
+		err = initHalo(h, cFunc, bt, d, m)
+	}
+	if err != nil {
+		return nil, &ConstructionError{bt, d, m, z, err}
+	}
+	return h, nil
+}
+
+// NewFromScaleRadius creates a new Halo instance with mass m, measured
+// under the overdensity definition d, and with the fixed pivot radius rs.
+// rs is the radius at which the logarithmic slope of the halo's true
+// density profile is -2 and is given in Mpc. If bt is Biased, m is the
+// biased mass of the halo. Otherwise it behaves like NewFromMass.
+func NewFromScaleRadius(cosmology cosmo.Cosmology, fTh FThermalModel, pp PressureProfile, mp MassProfile, bt BiasType, d DensityType, m, rs, z float64) (*Halo, error) {
+	h, err := newHalo(cosmology, fTh, pp, mp, bt, d, m, z)
+	if err == nil && rs <= 0 {
+		err = &ParameterError{"NewFromScaleRadius", "rs", rs, "rs > 0"}
+	}
+	if err == nil {
+		rho200c := C200.Density(cosmology, z)
+		cFunc := func(m200c float64) float64 {
+			return haloRadius(m200c, rho200c) / rs
+		}
+		err = initHalo(h, cFunc, bt, d, m)
+	}
+	if err != nil {
+		return nil, &ConstructionError{bt, d, m, z, err}
+	}
+	return h, nil
+}
+
+// newHalo validates the arguments of a constructor and creates a Halo
+// whose profile and DensityInfo fields have not yet been initialized.
+func newHalo(cosmology cosmo.Cosmology, fTh FThermalModel, pp PressureProfile, mp MassProfile, bt BiasType, d DensityType, m, z float64) (*Halo, error) {
+	if m < MinHaloMass || m > MaxHaloMass {
+		return nil, &MassBoundsError{m, MinHaloMass, MaxHaloMass}
+	}
+	if bt != Biased && bt != Corrected {
+		return nil, &EnumError{Type: "BiasType", Value: int(bt)}
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cosmology.Validate(); err != nil {
+		return nil, err
+	}
+
+	if fTh == nil {
+		return nil, &NilArgumentError{"FThermalModel"}
+	}
+	if err := validatePressureProfile(pp); err != nil {
+		return nil, err
 	}
 
 	h := new(Halo)
 	h.Z = z
-	h.ppt = ppt
-	h.mpt = NFW
+	h.cosmology = cosmology
+	h.pp = pp
+	h.fTh = fTh
+
+	if err := initProfile(h, mp, m); err != nil {
+		return nil, err
+	}
+	bindFThermal(h)
 
-	bFrac := BFracFunc(fTh, num.SecondOrder)
-	alphaBias := AlphaBiasFunc(fTh)
-	betaBias := BetaBiasFunc(fTh)
+	return h, nil
+}
+
+// bindFThermal sets the f_th-derived function fields of h so that they are
+// evaluated against h.
+func bindFThermal(h *Halo) {
+	bFrac := BFracFunc(h.fTh, num.SecondOrder)
+	alphaBias := AlphaBiasFunc(h.fTh)
+	betaBias := BetaBiasFunc(h.fTh)
 
-	h.FThermal = func(r float64) float64 { return fTh(h, r) }
+	h.FThermal = func(r float64) float64 { return h.fTh.FThermal(h, r) }
 	h.BFrac = func(r float64) float64 { return bFrac(h, r) }
 	h.AlphaBias = func(r float64) float64 { return alphaBias(h, r) }
 	h.BetaBias = func(r float64) float64 { return betaBias(h, r) }
 
+	// With b = (1 - beta/alpha) / f_th,
+	// d ln b / d ln r = (alpha' - beta')/(alpha - beta) - alpha'/alpha - beta.
+	h.dLnBFrac = nil
+	cm, okF := h.fTh.(FThermalCurvatureModel)
+	cp, okP := h.pp.(PressureCurvatureProfile)
+	if okF && okP {
+		h.dLnBFrac = func(r float64) float64 {
+			alpha, beta := h.AlphaBias(r), h.BetaBias(r)
+			s := h.pressureScale(ThermalPressure, h.pp)
+			dAlpha := cp.D2LnElectronPressureDLnR2(s, r)
+			dBeta := cm.D2LnFThermalDLnR2(h, r)
+			return (dAlpha-dBeta)/(alpha-beta) - dAlpha/alpha - beta
+		}
+	}
+}
+
+// clone returns a copy of a partially constructed h which constructors can
+// modify while searching for h's parameters. Cached DensityInfo values and
+// diagnostics are not copied.
+func (h *Halo) clone() *Halo {
+	c := &Halo{
+		A200: h.A200, C200: h.C200, C500: h.C500,
+		Z: h.Z, Rs: h.Rs, cosmology: h.cosmology,
+		M500cBias: h.M500cBias, R500cBias: h.R500cBias,
+		pp: h.pp, fTh: h.fTh,
+		mp: h.mp, shape: h.shape, model: h.model,
+		einastoAlpha: h.einastoAlpha, nu: h.nu,
+		trial: true,
+	}
+	bindFThermal(c)
+	return c
+}
+
+// record adds diag to h's diagnostics unless h is a trial halo.
+func (h *Halo) record(diag RootDiagnostics) {
+	if !h.trial {
+		h.diagnostics = append(h.diagnostics, diag)
+	}
+}
+
+// initOverdensityRadius is overdensityRadius for use during construction.
+// The search is labeled with quantity and recorded in h's diagnostics.
+func (h *Halo) initOverdensityRadius(quantity string, bt BiasType, rho float64) (float64, error) {
+	r, diag, err := h.solveOverdensityRadius(quantity, bt, rho)
+	if err != nil {
+		return r, err
+	}
+	h.record(diag)
+	return r, nil
+}
+
+// Cosmology returns the cosmology which h was constructed in.
+func (h *Halo) Cosmology() cosmo.Cosmology { return h.cosmology }
+
+// Diagnostics returns a description of every root-finding search which
+// determined the parameters of h, in the order that they were performed.
+// Searches over trial parameters which were later discarded are not
+// included.
+func (h *Halo) Diagnostics() []RootDiagnostics {
+	return append([]RootDiagnostics{}, h.diagnostics...)
+}
+
+// initProfile validates mp and sets up the shape of h's true density
+// profile. m is an estimate of the halo's mass which is used to give
+// provisional shapes to profiles which depend on mass.
+func initProfile(h *Halo, mp MassProfile, m float64) error {
+	if err := mp.Validate(); err != nil {
+		return err
+	}
+
+	h.mp = mp
+	if mp.Type == Einasto || mp.Type == DK14 {
+		h.nu = h.cosmology.PeakHeightFunc(h.cosmology.Sigma, h.Z)
+	}
+	if (mp.Type == Einasto || mp.Type == DK14) && mp.Alpha == 0 {
+		h.einastoAlpha = einastoAlphaFunc(h.nu)
+		h.shape = newProfileShape(mp, h.einastoAlpha(m))
+	} else {
+		h.shape = newProfileShape(mp, mp.Alpha)
+	}
+	return nil
+}
+
+// initHalo initializes the profile and DensityInfo fields of h such that it
+// has mass m under the overdensity definition d.
+func initHalo(h *Halo, cFunc num.Func1D, bt BiasType, d DensityType, m float64) error {
 	switch bt {
 	case Biased:
-		initBiasedHalo(h, cFunc, m500c)
+		return initBiasedHalo(h, cFunc, d, m)
 	case Corrected:
-		initCorrectedHalo(h, cFunc, m500c)
-	default:
-		panic("Unrecognized BiasType")
+		return initCorrectedHalo(h, cFunc, d, m)
 	}
-
-	return h
+	panic("Given unrecognized BiasType.")
 }
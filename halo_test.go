@@ -0,0 +1,185 @@
+package halo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// Number of goroutines which share each halo and model in TestConcurrentUse.
+const concurrentWorkers = 8
+
+// haloSummary collects the results of calling most of the methods of a Halo.
+type haloSummary struct {
+	densities []DensityInfo
+	values    []float64
+	diags     int
+}
+
+// summarize calls a representative set of h's methods. Every call, including
+// the ones which fill h's caches, can happen concurrently with calls from
+// other goroutines.
+func summarize(h *Halo, pp PressureProfile) (haloSummary, error) {
+	s := haloSummary{}
+	for _, bt := range []BiasType{Biased, Corrected} {
+		for _, d := range []DensityType{A200, A500, C200, C500, C2500, Virial} {
+			info, err := h.DensityInfo(bt, d)
+			if err != nil {
+				return s, err
+			}
+			s.densities = append(s.densities, info)
+		}
+	}
+
+	for _, x := range []float64{0.1, 0.5, 1} {
+		r := x * h.C500.R
+		s.values = append(s.values,
+			h.FThermal(r), h.BFrac(r), h.AlphaBias(r), h.BetaBias(r),
+			h.MassEnclosed(Biased, r), h.MassEnclosed(Corrected, r),
+			h.Rho(Corrected, r), h.RhoGas(Corrected, ThermalPressure, pp, r),
+			h.Pressure(ThermalPressure, pp, ElectronPressure, r),
+			h.DPdr(EffectivePressure, pp, AllPressure, r),
+			h.ThompsonY(ThermalPressure, pp, r),
+		)
+	}
+	s.values = append(s.values,
+		h.OverdensityRadius(Biased, 500*h.cosmology.RhoCritical(h.Z)),
+		h.SplashbackRadius(2),
+	)
+	s.diags = len(h.Diagnostics())
+	return s, nil
+}
+
+func (s haloSummary) equal(t haloSummary) bool {
+	if len(s.densities) != len(t.densities) || len(s.values) != len(t.values) ||
+		s.diags != t.diags {
+		return false
+	}
+	for i := range s.densities {
+		if s.densities[i] != t.densities[i] {
+			return false
+		}
+	}
+	for i := range s.values {
+		if s.values[i] != t.values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// concurrentModels returns f_th models and pressure profiles of each kind
+// which are shared between the goroutines of TestConcurrentUse.
+func concurrentModels(t *testing.T) ([]FThermalModel, []PressureProfile) {
+	battaglia, err := FThermalFunc(Battaglia2013, MeanCurve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shiKomatsu, err := ShiKomatsuFThermalFunc(2, etaShiKomatsu, betaShiKomatsu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := []float64{0.01, 0.1, 0.5, 1, 2, 4}
+	m500c := []float64{1e13, 1e14, 1e15}
+	z := []float64{0, 1}
+	fTh := make([]float64, len(x)*len(m500c)*len(z))
+	for i := range fTh {
+		ix := i % len(x)
+		fTh[i] = 0.95 - 0.1*float64(ix)/float64(len(x))
+	}
+	table, err := NewFThermalTable(x, m500c, z, fTh, FThermalExtrapolation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []FThermalModel{battaglia, shiKomatsu, table.RadialFunc()},
+		[]PressureProfile{BattagliaAGN2012, Arnaud2009, planck2012GNFW}
+}
+
+// TestConcurrentUse constructs halos and calls their methods from many
+// goroutines at once. The halos of each goroutine share their FThermalModel
+// and PressureProfile with every other goroutine, and each constructed halo
+// is shared by concurrentWorkers goroutines. The results must match those of
+// halos constructed and used serially. Run with -race.
+func TestConcurrentUse(t *testing.T) {
+	// No other test uses this cosmology, so its sigma tables are first
+	// built while the halos are constructed concurrently.
+	c := cosmo.WMAP7
+	cFunc, err := ConcentrationFunc(c, Bhattacharya2013, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fThs, pps := concurrentModels(t)
+	mps := []MassProfile{{Type: NFW}, {Type: Einasto}, {Type: DK14}}
+
+	type job struct {
+		name string
+		fTh  FThermalModel
+		pp   PressureProfile
+		mp   MassProfile
+	}
+	jobs := []job{}
+	for i, fTh := range fThs {
+		for j, pp := range pps {
+			mp := mps[(i+j)%len(mps)]
+			jobs = append(jobs, job{
+				fmt.Sprintf("fTh %d, pp %d, mass profile %d", i, j, mp.Type),
+				fTh, pp, mp,
+			})
+		}
+	}
+
+	// Halos constructed in one goroutine and shared by several.
+	shared := make([]*Halo, len(jobs))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jb := jobs[i]
+			h, err := New(c, jb.fTh, jb.pp, jb.mp, cFunc, Corrected, 3e14, 0.5)
+			if err != nil {
+				t.Errorf("%s: %s", jb.name, err)
+				return
+			}
+			shared[i] = h
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	// Serial results to compare against.
+	want := make([]haloSummary, len(jobs))
+	for i, jb := range jobs {
+		h, err := New(c, jb.fTh, jb.pp, jb.mp, cFunc, Corrected, 3e14, 0.5)
+		if err != nil {
+			t.Fatalf("%s: %s", jb.name, err)
+		}
+		if want[i], err = summarize(h, jb.pp); err != nil {
+			t.Fatalf("%s: %s", jb.name, err)
+		}
+	}
+
+	for i := range jobs {
+		for w := 0; w < concurrentWorkers; w++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				jb := jobs[i]
+				got, err := summarize(shared[i], jb.pp)
+				if err != nil {
+					t.Errorf("%s: %s", jb.name, err)
+				} else if !got.equal(want[i]) {
+					t.Errorf("%s: concurrent results differ from serial "+
+						"results", jb.name)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,321 @@
+package halo
+
+import (
+	"math"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// MassFunctionType specifies a fit to the halo mass function. Each fit gives
+// the multiplicity function f(sigma), which is related to the comoving number
+// density of halos per unit ln(M) by
+//
+//     dn/dlnM = f(sigma) (rho_m / M) |d ln(sigma) / d ln(M)|,
+//
+// where rho_m is the comoving average matter density and sigma(M) is given
+// by the cosmology's SigmaFunc.
+//
+// PressSchechter1974, ShethTormen1999, and Jenkins2001 are universal fits
+// which were calibrated against friends-of-friends halos with a linking
+// length of 0.2. These correspond to spherical overdensities of about 180
+// times the average density, which is also the overdensity of a collapsed
+// halo in the spherical collapse model, so they only support that
+// overdensity. Tinker2008 interpolates its parameters in Delta and supports
+// overdensities between 200 and 3200 times the average density. Despali2016
+// supports any overdensity through its dependence on Delta / Delta_vir.
+// Using a fit with an unsupported overdensity results in a *ParameterError.
+type MassFunctionType uint32
+
+const (
+	PressSchechter1974 MassFunctionType = iota
+	ShethTormen1999
+	Jenkins2001
+	Tinker2008
+	Despali2016
+
+	massFunctionTypeCount
+)
+
+const (
+	shethTormenA = 0.3222
+	shethTormena = 0.707
+	shethTormenP = 0.3
+
+	jenkinsA     = 0.315
+	jenkinsB     = 0.61
+	jenkinsPower = 3.8
+
+	// The overdensity, relative to the average density, of the halos
+	// which the universal fits describe, and the relative tolerance used
+	// when comparing overdensities to it.
+	fofDeltaM          = 180
+	fofDeltaMTolerance = 1e-6
+
+	tinkerAEvolution = -0.14
+	tinkeraEvolution = -0.06
+
+	// The mass function is integrated from the minimum mass up to
+	// massFunctionMaxM, in M_sun, with massFunctionSteps Simpson steps.
+	// Cluster counts are integrated over redshift with clusterCountSteps
+	// Simpson steps.
+	massFunctionMaxM  = 1e17
+	massFunctionSteps = 200
+	clusterCountSteps = 100
+
+	// Step in ln(M) used to compute d ln(sigma) / d ln(M).
+	massFunctionDLnM = 0.05
+)
+
+// The parameters of Tinker et al. (2008), Table 2, as a function of the
+// overdensity relative to the average density.
+var (
+	tinkerDelta = []float64{200, 300, 400, 600, 800, 1200, 1600, 2400, 3200}
+	tinkerA     = []float64{
+		0.186, 0.200, 0.212, 0.218, 0.248, 0.255, 0.260, 0.260, 0.260,
+	}
+	tinkera = []float64{
+		1.47, 1.52, 1.56, 1.61, 1.87, 2.13, 2.30, 2.53, 2.66,
+	}
+	tinkerB = []float64{
+		2.57, 2.25, 2.05, 1.87, 1.59, 1.51, 1.46, 1.44, 1.41,
+	}
+	tinkerC = []float64{
+		1.19, 1.27, 1.34, 1.45, 1.58, 1.80, 1.97, 2.24, 2.44,
+	}
+)
+
+// tinkerLogDelta is log10(tinkerDelta).
+var tinkerLogDelta = func() []float64 {
+	logDelta := make([]float64, len(tinkerDelta))
+	for i := range logDelta {
+		logDelta[i] = math.Log10(tinkerDelta[i])
+	}
+	return logDelta
+}()
+
+// deltaM returns the overdensity of d relative to the average matter density
+// at redshift z.
+func deltaM(cosmology cosmo.Cosmology, d DensityType, z float64) float64 {
+	return d.Density(cosmology, z) / cosmology.RhoAverage(z)
+}
+
+// checkFoFDelta returns an error if d does not have the overdensity of the
+// friends-of-friends halos which the universal fits were calibrated
+// against.
+func checkFoFDelta(model string, cosmology cosmo.Cosmology, d DensityType, z float64) error {
+	delta := deltaM(cosmology, d, z)
+	if !(math.Abs(delta/fofDeltaM-1) <= fofDeltaMTolerance) {
+		return &ParameterError{model, "Delta_m", delta, "Delta_m = 180"}
+	}
+	return nil
+}
+
+// tinkerDeltaM returns the overdensity of d relative to the average matter
+// density at redshift z. An error is returned if it is outside the range
+// over which the fits of Tinker et al. are calibrated.
+func tinkerDeltaM(model string, cosmology cosmo.Cosmology, d DensityType, z float64) (float64, error) {
+	delta := deltaM(cosmology, d, z)
+	minDelta, maxDelta := tinkerDelta[0], tinkerDelta[len(tinkerDelta)-1]
+	if !(delta >= minDelta && delta <= maxDelta) {
+		return 0, &ParameterError{model, "Delta_m", delta, "200 <= Delta_m <= 3200"}
+	}
+	return delta, nil
+}
+
+// multiplicityFunc returns the multiplicity function f(sigma) of the given
+// fit for halos with the overdensity definition d at redshift z.
+func multiplicityFunc(cosmology cosmo.Cosmology, mfType MassFunctionType, d DensityType, z float64) (num.Func1D, error) {
+	switch mfType {
+	case PressSchechter1974:
+		if err := checkFoFDelta("PressSchechter1974", cosmology, d, z); err != nil {
+			return nil, err
+		}
+		return func(sigma float64) float64 {
+			nu := cosmo.DeltaCollapse / sigma
+			return math.Sqrt(2/math.Pi) * nu * math.Exp(-nu*nu/2)
+		}, nil
+
+	case ShethTormen1999:
+		if err := checkFoFDelta("ShethTormen1999", cosmology, d, z); err != nil {
+			return nil, err
+		}
+		return func(sigma float64) float64 {
+			nu := cosmo.DeltaCollapse / sigma
+			nu2 := shethTormena * nu * nu
+			return shethTormenA * math.Sqrt(2*nu2/math.Pi) *
+				(1 + math.Pow(nu2, -shethTormenP)) * math.Exp(-nu2/2)
+		}, nil
+
+	case Jenkins2001:
+		if err := checkFoFDelta("Jenkins2001", cosmology, d, z); err != nil {
+			return nil, err
+		}
+		return func(sigma float64) float64 {
+			x := math.Abs(jenkinsB - math.Log(sigma))
+			return jenkinsA * math.Exp(-math.Pow(x, jenkinsPower))
+		}, nil
+
+	case Tinker2008:
+		delta, err := tinkerDeltaM("Tinker2008", cosmology, d, z)
+		if err != nil {
+			return nil, err
+		}
+
+		x := math.Log10(delta)
+		alpha := math.Pow(10, -math.Pow(0.75/math.Log10(delta/75), 1.2))
+
+		A := splineEval(tinkerLogDelta, tinkerA, x, ClampExtrapolation) *
+			math.Pow(1+z, tinkerAEvolution)
+		a := splineEval(tinkerLogDelta, tinkera, x, ClampExtrapolation) *
+			math.Pow(1+z, tinkeraEvolution)
+		b := splineEval(tinkerLogDelta, tinkerB, x, ClampExtrapolation) *
+			math.Pow(1+z, -alpha)
+		c := splineEval(tinkerLogDelta, tinkerC, x, ClampExtrapolation)
+
+		return func(sigma float64) float64 {
+			return A * (math.Pow(sigma/b, -a) + 1) * math.Exp(-c/(sigma*sigma))
+		}, nil
+
+	case Despali2016:
+		x := math.Log10(d.Density(cosmology, z) /
+			(bryanNormanDelta(cosmology, z) * cosmology.RhoCritical(z)))
+		A := -0.1362*x + 0.3292
+		a := 0.4332*x*x + 0.2263*x + 0.7665
+		p := -0.1151*x*x + 0.2554*x + 0.2488
+
+		omegaM := cosmology.RhoAverage(z) / cosmology.RhoCritical(z)
+		deltaC := cosmo.DeltaCollapse * (1 + 0.0123*math.Log10(omegaM))
+
+		return func(sigma float64) float64 {
+			nu := a * (deltaC / sigma) * (deltaC / sigma)
+			return 2 * A * (1 + math.Pow(nu, -p)) *
+				math.Sqrt(nu/(2*math.Pi)) * math.Exp(-nu/2)
+		}, nil
+	}
+
+	return nil, &EnumError{Type: "MassFunctionType", Value: int(mfType)}
+}
+
+// MassFunction returns a function which gives the comoving number density of
+// halos per unit ln(M), dn/dlnM, in Mpc^-3 at the mass M, in M_sun, under
+// the overdensity definition d at redshift z in the given cosmology. The
+// returned function can be used as the Prior of a MassPosteriorConfig.
+//
+// sigma(M) is evaluated at the mass under d, as is conventional for these
+// fits, using cosmology.Sigma.
+func MassFunction(cosmology cosmo.Cosmology, mfType MassFunctionType, d DensityType, z float64) (num.Func1D, error) {
+	if err := cosmology.Validate(); err != nil {
+		return nil, err
+	} else if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	f, err := multiplicityFunc(cosmology, mfType, d, z)
+	if err != nil {
+		return nil, err
+	}
+	sigma := cosmology.SigmaFunc(cosmology.Sigma, z)
+	return massFunction(f, sigma, cosmology.RhoAverage(0)), nil
+}
+
+// massFunction returns dn/dlnM for the multiplicity function f, where sigma
+// gives sigma(M) and rhoM is the comoving average matter density.
+func massFunction(f, sigma num.Func1D, rhoM float64) num.Func1D {
+	return func(m float64) float64 {
+		step := math.Exp(massFunctionDLnM)
+		dLnSigma := math.Log(sigma(m*step)/sigma(m/step)) /
+			(2 * massFunctionDLnM)
+		return f(sigma(m)) * rhoM / m * math.Abs(dLnSigma)
+	}
+}
+
+// simpson integrates f from lo to hi with Simpson's rule using n steps,
+// where n is even.
+func simpson(f num.Func1D, lo, hi float64, n int) float64 {
+	h := (hi - lo) / float64(n)
+	sum := f(lo) + f(hi)
+	for i := 1; i < n; i++ {
+		if i%2 == 1 {
+			sum += 4 * f(lo+h*float64(i))
+		} else {
+			sum += 2 * f(lo+h*float64(i))
+		}
+	}
+	return sum * h / 3
+}
+
+// numberDensity integrates dn/dlnM above mMin.
+func numberDensity(dndlnm num.Func1D, mMin float64) float64 {
+	if mMin >= massFunctionMaxM {
+		return 0
+	}
+	return simpson(func(lnM float64) float64 {
+		return dndlnm(math.Exp(lnM))
+	}, math.Log(mMin), math.Log(massFunctionMaxM), massFunctionSteps)
+}
+
+// NumberDensity returns the comoving number density, in Mpc^-3, of halos
+// with masses above mMin, in M_sun, under the overdensity definition d at
+// redshift z in the given cosmology.
+func NumberDensity(cosmology cosmo.Cosmology, mfType MassFunctionType, d DensityType, mMin, z float64) (float64, error) {
+	if !(mMin > 0) {
+		return 0, &ParameterError{"NumberDensity", "mMin", mMin, "mMin > 0"}
+	}
+
+	dndlnm, err := MassFunction(cosmology, mfType, d, z)
+	if err != nil {
+		return 0, err
+	}
+	return numberDensity(dndlnm, mMin), nil
+}
+
+// ClusterCount returns the expected number of halos with masses above mMin,
+// in M_sun, under the overdensity definition d, between the redshifts zMin
+// and zMax in a survey covering solidAngle steradians. The full sky is
+// 4 pi steradians.
+func ClusterCount(cosmology cosmo.Cosmology, mfType MassFunctionType, d DensityType, mMin, zMin, zMax, solidAngle float64) (float64, error) {
+	if !(mMin > 0) {
+		return 0, &ParameterError{"ClusterCount", "mMin", mMin, "mMin > 0"}
+	} else if !(zMin >= 0 && zMax > zMin) {
+		return 0, &ParameterError{"ClusterCount", "zMax", zMax, "0 <= zMin < zMax"}
+	} else if !(solidAngle >= 0 && solidAngle <= 4*math.Pi) {
+		return 0, &ParameterError{"ClusterCount", "solidAngle", solidAngle,
+			"0 <= solidAngle <= 4 pi"}
+	}
+
+	if err := cosmology.Validate(); err != nil {
+		return 0, err
+	} else if err := d.Validate(); err != nil {
+		return 0, err
+	}
+	// Check the overdensity at both ends of the redshift range once, so
+	// that errors are not lost in the integrand.
+	for _, z := range []float64{zMin, zMax} {
+		if _, err := multiplicityFunc(cosmology, mfType, d, z); err != nil {
+			return 0, err
+		}
+	}
+
+	// sigma(M, z) = D(z) sigma(M, 0), so sigma(M) only needs to be set up
+	// once.
+	sigma0 := cosmology.SigmaFunc(cosmology.Sigma, 0)
+	rhoM := cosmology.RhoAverage(0)
+
+	var err error
+	count := simpson(func(z float64) float64 {
+		f, zErr := multiplicityFunc(cosmology, mfType, d, z)
+		if zErr != nil {
+			err = zErr
+			return 0
+		}
+		growth := cosmology.GrowthFactor(1 / (1 + z))
+		sigma := func(m float64) float64 { return growth * sigma0(m) }
+		dndlnm := massFunction(f, sigma, rhoM)
+		return cosmology.ComovingVolumeElement(z) * numberDensity(dndlnm, mMin)
+	}, zMin, zMax, clusterCountSteps)
+	if err != nil {
+		return 0, err
+	}
+	return count * solidAngle, nil
+}
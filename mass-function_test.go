@@ -0,0 +1,108 @@
+package halo
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// fof is the overdensity definition which the universal fits support.
+var fof = DensityType{fofDeltaM, AverageDensity}
+
+// TestPressSchechterNormalization checks that the Press-Schechter
+// multiplicity function, which includes Press & Schechter's factor of two,
+// places all of the mass in halos: int f(sigma) dln(1/sigma) = 1.
+func TestPressSchechterNormalization(t *testing.T) {
+	f, err := multiplicityFunc(cosmo.Fiducial, PressSchechter1974, fof, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integral := simpson(func(lnInvSigma float64) float64 {
+		return f(math.Exp(-lnInvSigma))
+	}, -25, 4, 4000)
+	if math.Abs(integral-1) > 1e-8 {
+		t.Errorf("int f dln(1/sigma) = %.10g, expected 1", integral)
+	}
+}
+
+func TestTinkerTable2(t *testing.T) {
+	// Rows of Table 2 of Tinker et al. (2008): Delta_m, A, a, b, c.
+	rows := [][5]float64{
+		{200, 0.186, 1.47, 2.57, 1.19},
+		{800, 0.248, 1.87, 1.59, 1.58},
+		{3200, 0.260, 2.66, 1.41, 2.44},
+	}
+	for _, row := range rows {
+		d := DensityType{row[0], AverageDensity}
+		f, err := multiplicityFunc(cosmo.Fiducial, Tinker2008, d, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		A, a, b, c := row[1], row[2], row[3], row[4]
+		for _, sigma := range []float64{0.3, 0.7, 1, 2} {
+			want := A * (math.Pow(sigma/b, -a) + 1) * math.Exp(-c/(sigma*sigma))
+			if got := f(sigma); math.Abs(got/want-1) > 1e-12 {
+				t.Errorf("Delta_m = %g: f(%g) = %.12g, Table 2 gives %.12g",
+					row[0], sigma, got, want)
+			}
+		}
+	}
+}
+
+func TestMassFunctionDensityType(t *testing.T) {
+	for _, mfType := range []MassFunctionType{
+		PressSchechter1974, ShethTormen1999, Jenkins2001,
+	} {
+		if _, err := MassFunction(cosmo.Fiducial, mfType, fof, 0.5); err != nil {
+			t.Errorf("MassFunctionType %d with 180m: %s", mfType, err)
+		}
+		_, err := MassFunction(cosmo.Fiducial, mfType, A200, 0.5)
+		var pErr *ParameterError
+		if !errors.As(err, &pErr) {
+			t.Errorf("MassFunctionType %d with 200m gave error %v, expected "+
+				"a *ParameterError", mfType, err)
+		}
+	}
+
+	_, err := MassFunction(cosmo.Fiducial, Tinker2008, A200, 0.5)
+	if err != nil {
+		t.Errorf("Tinker2008 with 200m: %s", err)
+	}
+	_, err = MassFunction(cosmo.Fiducial, Tinker2008, fof, 0.5)
+	var pErr *ParameterError
+	if !errors.As(err, &pErr) {
+		t.Errorf("Tinker2008 with 180m gave error %v, expected a "+
+			"*ParameterError", err)
+	}
+}
+
+// TestClusterCount compares ClusterCount against the integral of
+// NumberDensity over the comoving volume.
+func TestClusterCount(t *testing.T) {
+	c := cosmo.Fiducial
+	mMin, zMin, zMax := 1e14, 0.1, 1.2
+	for _, mfType := range []MassFunctionType{Tinker2008, Despali2016} {
+		var err error
+		want := simpson(func(z float64) float64 {
+			n, zErr := NumberDensity(c, mfType, C500, mMin, z)
+			if zErr != nil {
+				err = zErr
+			}
+			return c.ComovingVolumeElement(z) * n
+		}, zMin, zMax, clusterCountSteps)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ClusterCount(c, mfType, C500, mMin, zMin, zMax, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got/want-1) > 1e-10 {
+			t.Errorf("MassFunctionType %d: ClusterCount = %.12g, expected "+
+				"%.12g", mfType, got, want)
+		}
+	}
+}
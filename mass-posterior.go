@@ -0,0 +1,247 @@
+package halo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+// MassPosteriorConfig describes a hydrostatic mass measurement and the model
+// used to infer the true mass of the halo it was measured from.
+//
+// The measured mass, MBias, is an estimate of M500cBias with a log-normal
+// uncertainty SigmaLnM. The f_th relation is marginalized over by drawing
+// Samples parameter vectors from Params with the given Seed. To use a single
+// f_th relation, give Params no parameters and a Func which returns it.
+//
+// The posterior is evaluated on a grid of Bins true M500c values spaced
+// uniformly in log between MinM500c and MaxM500c. The grid should contain
+// essentially all of the posterior.
+type MassPosteriorConfig struct {
+	MBias, SigmaLnM float64
+	Z               float64
+	Cosmology       cosmo.Cosmology
+
+	// Prior gives the prior density of true M500c per unit ln(M500c), e.g.
+	// the halo mass function returned by MassFunction for C500. If Prior is
+	// nil, the prior is uniform in ln(M500c).
+	Prior num.Func1D
+
+	Params  *FThermalParams
+	Samples int
+	Seed    int64
+
+	Pressure      PressureProfile
+	MassProfile   MassProfile
+	Concentration num.Func1D
+
+	MinM500c, MaxM500c float64
+	Bins               int
+
+	// Workers is passed to EvalBatch.
+	Workers int
+}
+
+// MassPosterior is the posterior distribution of the true M500c of a halo
+// given a hydrostatic mass measurement, tabulated on a grid. PDF[i] is the
+// probability density per unit ln(M500c) at M500c[i] and is normalized to
+// integrate to one over the grid.
+//
+// Params contains the f_th parameter vectors which were marginalized over.
+// If a halo cannot be constructed from a draw at some grid point, that draw
+// is left out of the marginalization at that point only, and the failure is
+// recorded in Failed. Draws[i] is the number of draws which were used at
+// M500c[i]. Failures which depend on the f_th parameters bias the posterior
+// at the affected points towards the parameters which succeeded, so any
+// point with Draws[i] well below len(Params) should be treated with care.
+type MassPosterior struct {
+	M500c  []float64
+	PDF    []float64
+	Params [][]float64
+	Draws  []int
+	Failed []*SampleError
+	cdf    []float64
+}
+
+// NoDrawsError is returned by TrueMassPosterior when every draw of the f_th
+// parameters failed at the grid point M500c. Err is the error of the first
+// draw.
+type NoDrawsError struct {
+	M500c float64
+	Err   error
+}
+
+func (e *NoDrawsError) Error() string {
+	return fmt.Sprintf("halo: MassPosterior has no successful draws at "+
+		"M500c = %.5g: %v", e.M500c, e.Err)
+}
+
+func (e *NoDrawsError) Unwrap() error { return e.Err }
+
+// NormalizationError is returned by TrueMassPosterior when the posterior
+// cannot be normalized on the grid [MinM500c, MaxM500c] because its integral,
+// Total, is zero, infinite, or NaN.
+type NormalizationError struct {
+	MinM500c, MaxM500c float64
+	Total              float64
+}
+
+func (e *NormalizationError) Error() string {
+	return fmt.Sprintf("halo: MassPosterior cannot be normalized on "+
+		"[%.5g, %.5g]: integral is %.5g", e.MinM500c, e.MaxM500c, e.Total)
+}
+
+// TrueMassPosterior computes the posterior distribution of a halo's true
+// M500c given the measurement and model described by cfg. Each draw of the
+// f_th parameters requires a halo to be constructed at every grid point, so
+// the cost scales as cfg.Samples * cfg.Bins. An error is returned if cfg is
+// invalid, if ctx is cancelled, if no draw succeeded at some grid point, or
+// if the posterior vanishes everywhere on the grid.
+func TrueMassPosterior(ctx context.Context, cfg MassPosteriorConfig) (*MassPosterior, error) {
+	if !(cfg.MBias > 0) {
+		return nil, &ParameterError{"MassPosteriorConfig", "MBias", cfg.MBias, "MBias > 0"}
+	} else if !(cfg.SigmaLnM > 0) {
+		return nil, &ParameterError{"MassPosteriorConfig", "SigmaLnM", cfg.SigmaLnM, "SigmaLnM > 0"}
+	} else if cfg.Samples <= 0 {
+		return nil, &ParameterError{"MassPosteriorConfig", "Samples", float64(cfg.Samples), "Samples > 0"}
+	} else if cfg.Bins < 2 {
+		return nil, &ParameterError{"MassPosteriorConfig", "Bins", float64(cfg.Bins), "Bins >= 2"}
+	} else if !(cfg.MinM500c > 0) {
+		return nil, &ParameterError{"MassPosteriorConfig", "MinM500c", cfg.MinM500c, "MinM500c > 0"}
+	} else if !(cfg.MaxM500c > cfg.MinM500c) {
+		return nil, &ParameterError{"MassPosteriorConfig", "MaxM500c", cfg.MaxM500c, "MaxM500c > MinM500c"}
+	} else if cfg.Params == nil {
+		return nil, &NilArgumentError{"FThermalParams"}
+	}
+
+	params, err := cfg.Params.Sample(rand.New(rand.NewSource(cfg.Seed)),
+		cfg.Samples)
+	if err != nil {
+		return nil, err
+	}
+
+	post := &MassPosterior{M500c: make([]float64, cfg.Bins)}
+	lnMin, lnMax := math.Log(cfg.MinM500c), math.Log(cfg.MaxM500c)
+	dlnM := (lnMax - lnMin) / float64(cfg.Bins-1)
+	for i := range post.M500c {
+		post.M500c[i] = math.Exp(lnMin + dlnM*float64(i))
+	}
+
+	specs := make([]HaloSpec, 0, len(params)*cfg.Bins)
+	for _, p := range params {
+		fTh := cfg.Params.Func(p)
+		for _, m := range post.M500c {
+			specs = append(specs, HaloSpec{
+				Cosmology: cfg.Cosmology,
+				FThermal:  fTh, Pressure: cfg.Pressure,
+				MassProfile: cfg.MassProfile, Concentration: cfg.Concentration,
+				Bias: Corrected, M500c: m, Z: cfg.Z,
+			})
+		}
+	}
+
+	results, err := EvalBatch(ctx, specs, cfg.Workers,
+		func(h *Halo) ([]float64, error) { return []float64{h.M500cBias}, nil })
+	if err != nil {
+		return nil, err
+	}
+
+	// Marginalize the likelihood of the measurement over the draws, using
+	// in each bin only the draws which succeeded there.
+	like := make([]float64, cfg.Bins)
+	post.Draws = make([]int, cfg.Bins)
+	lnMBias := math.Log(cfg.MBias)
+	norm := 1 / (math.Sqrt(2*math.Pi) * cfg.SigmaLnM)
+	for k, p := range params {
+		used := false
+		for i, res := range results[k*cfg.Bins : (k+1)*cfg.Bins] {
+			if res.Err != nil {
+				post.Failed = append(post.Failed, &SampleError{k, p,
+					fmt.Errorf("M500c = %g: %w", post.M500c[i], res.Err)})
+				continue
+			}
+			d := (lnMBias - math.Log(res.Values[0])) / cfg.SigmaLnM
+			like[i] += norm * math.Exp(-d*d/2)
+			post.Draws[i]++
+			used = true
+		}
+		if used {
+			post.Params = append(post.Params, p)
+		}
+	}
+	for i, n := range post.Draws {
+		if n == 0 {
+			return nil, &NoDrawsError{post.M500c[i], results[i].Err}
+		}
+		like[i] /= float64(n)
+	}
+
+	post.PDF = make([]float64, cfg.Bins)
+	for i, m := range post.M500c {
+		prior := 1.0
+		if cfg.Prior != nil {
+			prior = cfg.Prior(m)
+		}
+		post.PDF[i] = prior * like[i]
+	}
+
+	post.cdf = make([]float64, cfg.Bins)
+	for i := 1; i < cfg.Bins; i++ {
+		post.cdf[i] = post.cdf[i-1] + (post.PDF[i-1]+post.PDF[i])*dlnM/2
+	}
+	total := post.cdf[cfg.Bins-1]
+	if !(total > 0) || math.IsInf(total, 0) {
+		return nil, &NormalizationError{cfg.MinM500c, cfg.MaxM500c, total}
+	}
+	for i := range post.PDF {
+		post.PDF[i] /= total
+		post.cdf[i] /= total
+	}
+
+	return post, nil
+}
+
+// Percentile returns the q-th percentile of the true M500c, for
+// 0 <= q <= 100, interpolating the cumulative distribution linearly in
+// ln(M500c).
+func (p *MassPosterior) Percentile(q float64) float64 {
+	if q < 0 || q > 100 {
+		return math.NaN()
+	}
+
+	target, n := q/100, len(p.cdf)
+	for i := 1; i < n; i++ {
+		if p.cdf[i] >= target && p.cdf[i] > p.cdf[i-1] {
+			frac := (target - p.cdf[i-1]) / (p.cdf[i] - p.cdf[i-1])
+			lnM := math.Log(p.M500c[i-1])*(1-frac) + math.Log(p.M500c[i])*frac
+			return math.Exp(lnM)
+		}
+	}
+	return p.M500c[n-1]
+}
+
+// Mean returns the mean of the true M500c.
+func (p *MassPosterior) Mean() float64 {
+	dlnM := math.Log(p.M500c[1] / p.M500c[0])
+	sum := 0.0
+	for i := 1; i < len(p.M500c); i++ {
+		sum += (p.M500c[i-1]*p.PDF[i-1] + p.M500c[i]*p.PDF[i]) * dlnM / 2
+	}
+	return sum
+}
+
+// Mode returns the grid point with the highest posterior density per unit
+// ln(M500c).
+func (p *MassPosterior) Mode() float64 {
+	iMax := 0
+	for i := range p.PDF {
+		if p.PDF[i] > p.PDF[iMax] {
+			iMax = i
+		}
+	}
+	return p.M500c[iMax]
+}
@@ -0,0 +1,328 @@
+package halo
+
+import (
+	"fmt"
+	"math"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+const (
+	gaoAlpha0   = 0.155
+	gaoAlphaNu2 = 0.0095
+
+	dk14Beta       = 4.0
+	dk14Gamma      = 8.0
+	dk14Be         = 1.0
+	dk14Se         = 1.5
+	dk14RtFrac0    = 1.9
+	dk14RtFracNu   = -0.18
+	dk14OuterPivot = 5.0
+	// The mass interior to this fraction of r_-2 is neglected.
+	dk14MinRFrac = 1e-4
+
+	moreA     = 0.54
+	moreB     = 0.53
+	moreC     = 1.36
+	moreGamma = 3.04
+)
+
+// MassProfile specifies the shape of a halo's true density profile. Alpha,
+// Beta, and Gamma are shape parameters which are only used by some profile
+// types:
+//
+// Einasto profiles have rho ~ exp(-(2/Alpha) ((r/r_-2)^Alpha - 1)). If Alpha
+// is zero it will be computed from the peak height of the halo through the
+// relation alpha = 0.155 + 0.0095 nu^2 (Gao et al., 2008).
+//
+// GeneralizedNFW profiles have
+// rho ~ (r/r_s)^-Gamma (1 + (r/r_s)^Alpha)^((Gamma - Beta)/Alpha). Alpha must be
+// positive and Gamma < 2 < 3 < Beta so that the profile has a finite
+// enclosed mass and a well-defined r_-2. Alpha = 1, Beta = 3 is NFW.
+//
+// DK14 profiles (Diemer & Kravtsov, 2014) are Einasto profiles with shape
+// parameter Alpha which are steepened beyond a truncation radius r_t and
+// which transition to an infalling outer profile:
+//
+//     rho = rho_s exp(-(2/Alpha) ((r/r_-2)^Alpha - 1)) (1 + (r/r_t)^Beta)^(-Gamma/Beta)
+//         + rho_m (Be (r / 5 R200m)^-Se + 1)
+//
+// where rho_m is the mean matter density. RtFrac is r_t / R200m. Zero values
+// of Alpha, Beta, Gamma, RtFrac, Be, and Se select the DK14 defaults for
+// mass-selected samples: Alpha is found from the Gao et al. relation,
+// Beta = 4, Gamma = 8, RtFrac = 1.9 - 0.18 nu200m, Be = 1, and Se = 1.5. When
+// computing R200m and nu200m for these defaults, the truncation and infall
+// terms are neglected.
+//
+// NFW and Hernquist profiles ignore all of these parameters.
+//
+// In every case a halo's concentration is defined relative to the radius
+// r_-2 at which the logarithmic slope of the inner density profile is -2, so
+// concentration relations calibrated against NFW halos can be used directly.
+type MassProfile struct {
+	Type               MassProfileType
+	Alpha, Beta, Gamma float64
+	RtFrac, Be, Se     float64
+}
+
+// ParameterError is returned when a parameterized model is given parameters
+// outside of its range of validity.
+type ParameterError struct {
+	Model string
+	Name  string
+	Value float64
+	Valid string
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("halo: %s parameter %s = %.5g is invalid; must satisfy %s",
+		e.Model, e.Name, e.Value, e.Valid)
+}
+
+// Validate returns an error if the parameters of mp are invalid for its type.
+func (mp MassProfile) Validate() error {
+	switch mp.Type {
+	case NFW, Hernquist:
+		return nil
+	case Einasto:
+		if mp.Alpha < 0 {
+			return &ParameterError{"Einasto", "Alpha", mp.Alpha, "Alpha >= 0"}
+		}
+		return nil
+	case DK14:
+		params := []struct {
+			name  string
+			value float64
+		}{
+			{"Alpha", mp.Alpha}, {"Beta", mp.Beta}, {"Gamma", mp.Gamma},
+			{"RtFrac", mp.RtFrac}, {"Be", mp.Be}, {"Se", mp.Se},
+		}
+		for _, p := range params {
+			if p.value < 0 {
+				return &ParameterError{
+					"DK14", p.name, p.value, p.name + " >= 0",
+				}
+			}
+		}
+		if mp.Se >= 3 {
+			return &ParameterError{"DK14", "Se", mp.Se, "Se < 3"}
+		}
+		return nil
+	case GeneralizedNFW:
+		if mp.Alpha <= 0 {
+			return &ParameterError{
+				"GeneralizedNFW", "Alpha", mp.Alpha, "Alpha > 0",
+			}
+		} else if mp.Gamma >= 2 {
+			return &ParameterError{
+				"GeneralizedNFW", "Gamma", mp.Gamma, "Gamma < 2",
+			}
+		} else if mp.Beta <= 3 {
+			return &ParameterError{
+				"GeneralizedNFW", "Beta", mp.Beta, "Beta > 3",
+			}
+		}
+		return nil
+	}
+	return &EnumError{Type: "MassProfileType", Value: int(mp.Type)}
+}
+
+// profileShape describes the shape of a density profile in terms of
+// x = r / r_-2. rho is the density up to a constant, m is the corresponding
+// enclosed mass, m(x) = int_0^x dx' x'^2 rho(x'), and dLnRho is
+// d ln rho / d ln x.
+type profileShape struct {
+	rho    num.Func1D
+	m      num.Func1D
+	dLnRho num.Func1D
+}
+
+// densityModel is the true density profile of a specific halo. rho is in
+// cosmological units, m is in M_sun, and dLnRho is d ln rho / d ln r.
+type densityModel struct {
+	rho    num.Func1D
+	m      num.Func1D
+	dLnRho num.Func1D
+}
+
+// einastoAlphaFunc returns a function which computes the Einasto shape
+// parameter of a halo from its m200c, given its peak height function.
+func einastoAlphaFunc(peakHeight num.Func1D) num.Func1D {
+	return func(m200c float64) float64 {
+		nu := peakHeight(m200c)
+		return gaoAlpha0 + gaoAlphaNu2*nu*nu
+	}
+}
+
+// newProfileShape creates the profileShape of a validated MassProfile. alpha
+// overrides mp.Alpha for Einasto and DK14 profiles. For DK14 profiles, only
+// the inner Einasto term is described.
+func newProfileShape(mp MassProfile, alpha float64) profileShape {
+	switch mp.Type {
+	case NFW:
+		return profileShape{
+			rho:    func(x float64) float64 { return 1 / (x * (1 + x) * (1 + x)) },
+			m:      mNFW,
+			dLnRho: func(x float64) float64 { return -1 - 2*x/(1+x) },
+		}
+
+	case Hernquist:
+		// r_s = 2 r_-2.
+		return profileShape{
+			rho: func(x float64) float64 {
+				y := x / 2
+				return 1 / (y * (1 + y) * (1 + y) * (1 + y))
+			},
+			m: func(x float64) float64 {
+				y := x / 2
+				return 4 * y * y / ((1 + y) * (1 + y))
+			},
+			dLnRho: func(x float64) float64 {
+				y := x / 2
+				return -1 - 3*y/(1+y)
+			},
+		}
+
+	case Einasto, DK14:
+		norm := math.Exp(2/alpha) / alpha * math.Pow(alpha/2, 3/alpha) *
+			math.Gamma(3/alpha)
+		return profileShape{
+			rho: func(x float64) float64 {
+				return math.Exp(-2 / alpha * (math.Pow(x, alpha) - 1))
+			},
+			m: func(x float64) float64 {
+				return norm * regGammaP(3/alpha, 2/alpha*math.Pow(x, alpha))
+			},
+			dLnRho: func(x float64) float64 {
+				return -2 * math.Pow(x, alpha)
+			},
+		}
+
+	case GeneralizedNFW:
+		a, b, g := mp.Alpha, mp.Beta, mp.Gamma
+		// r_s / r_-2
+		s := math.Pow((b-2)/(2-g), 1/a)
+		p, q := (3-g)/a, (b-3)/a
+		norm := s * s * s / a * betaFunc(p, q)
+		return profileShape{
+			rho: func(x float64) float64 {
+				y := x / s
+				return math.Pow(y, -g) * math.Pow(1+math.Pow(y, a), (g-b)/a)
+			},
+			m: func(x float64) float64 {
+				ya := math.Pow(x/s, a)
+				return norm * regBetaI(p, q, ya/(1+ya))
+			},
+			dLnRho: func(x float64) float64 {
+				ya := math.Pow(x/s, a)
+				return -g + (g-b)*ya/(1+ya)
+			},
+		}
+	}
+	panic("Given unrecognized MassProfileType.")
+}
+
+// newDensityModel creates the densityModel of a halo with a validated
+// MassProfile, the given 200c DensityInfo, and a profile shape and r_-2
+// corresponding to them in the cosmology c. The peak height function nu is
+// only used by DK14 profiles which rely on the default truncation radius.
+// An error is returned if R200m of a DK14 profile's inner profile cannot be
+// found.
+func newDensityModel(mp MassProfile, shape profileShape, c200 DensityInfo, rs float64, c cosmo.Cosmology, z float64, nu num.Func1D) (densityModel, error) {
+	if mp.Type != DK14 {
+		ampl := c200.M / (4.0 * math.Pi * rs * rs * rs * shape.m(c200.C))
+		norm := c200.M / shape.m(c200.C)
+		return densityModel{
+			rho:    func(r float64) float64 { return ampl * shape.rho(r/rs) },
+			m:      func(r float64) float64 { return norm * shape.m(r/rs) },
+			dLnRho: func(r float64) float64 { return shape.dLnRho(r / rs) },
+		}, nil
+	}
+	return newDK14Model(mp, shape, c200, rs, c, z, nu)
+}
+
+func newDK14Model(mp MassProfile, shape profileShape, c200 DensityInfo, rs float64, c cosmo.Cosmology, z float64, nu num.Func1D) (densityModel, error) {
+	beta, gamma := valueOr(mp.Beta, dk14Beta), valueOr(mp.Gamma, dk14Gamma)
+	be, se := valueOr(mp.Be, dk14Be), valueOr(mp.Se, dk14Se)
+
+	rhoM := c.RhoAverage(z)
+
+	// Find R200m of the untruncated inner profile.
+	einastoNorm := c200.M / shape.m(c200.C)
+	einastoDensity := func(r float64) float64 {
+		return haloDensity(r, einastoNorm*shape.m(r/rs))
+	}
+	r200m, _, err := findEqualConst("R200m", einastoDensity, 200*rhoM, c200.R)
+	if err != nil {
+		return densityModel{}, err
+	}
+
+	rt := mp.RtFrac * r200m
+	if mp.RtFrac == 0 {
+		nu200m := nu(haloMass(r200m, 200*rhoM))
+		rt = (dk14RtFrac0 + dk14RtFracNu*nu200m) * r200m
+	}
+	rPivot := dk14OuterPivot * r200m
+
+	innerShape := func(r float64) float64 {
+		return shape.rho(r/rs) * math.Pow(1+math.Pow(r/rt, beta), -gamma/beta)
+	}
+	innerLnSlope := func(r float64) float64 {
+		tb := math.Pow(r/rt, beta)
+		return shape.dLnRho(r/rs) - gamma*tb/(1+tb)
+	}
+	innerMass := num.Integral(innerShape, dk14MinRFrac*rs, 0.1,
+		num.Log, num.Spherical)
+
+	outerRho := func(r float64) float64 {
+		return rhoM * (be*math.Pow(r/rPivot, -se) + 1)
+	}
+	// d outerRho / d ln r
+	outerSlope := func(r float64) float64 {
+		return -se * rhoM * be * math.Pow(r/rPivot, -se)
+	}
+	outerMass := func(r float64) float64 {
+		return 4 * math.Pi * rhoM * (be*math.Pow(rPivot, se)*
+			math.Pow(r, 3-se)/(3-se) + r*r*r/3)
+	}
+
+	rhoS := (c200.M - outerMass(c200.R)) / innerMass(c200.R)
+
+	return densityModel{
+		rho: func(r float64) float64 {
+			return rhoS*innerShape(r) + outerRho(r)
+		},
+		m: func(r float64) float64 {
+			return rhoS*innerMass(r) + outerMass(r)
+		},
+		dLnRho: func(r float64) float64 {
+			inner := rhoS * innerShape(r)
+			return (inner*innerLnSlope(r) + outerSlope(r)) /
+				(inner + outerRho(r))
+		},
+	}, nil
+}
+
+func valueOr(x, def float64) float64 {
+	if x == 0 {
+		return def
+	}
+	return x
+}
+
+// SplashbackRadius computes the splashback radius of h using the relation
+// between R_sp / R200m and the mass accretion rate,
+// Gamma = d log M / d log a, given by More, Diemer, & Kravtsov (2015):
+//
+//     R_sp / R200m = 0.54 (1 + 0.53 OmegaM(z)) (1 + 1.36 exp(-Gamma / 3.04))
+//
+// The returned value is in Mpc.
+func (h *Halo) SplashbackRadius(accretionRate float64) float64 {
+	a := 1 / (1 + h.Z)
+	e := h.cosmology.HubbleFrac(h.Z)
+	omegaM := h.cosmology.OmegaM / (a * a * a * e * e)
+
+	return moreA * (1 + moreB*omegaM) *
+		(1 + moreC*math.Exp(-accretionRate/moreGamma)) * h.A200.R
+}
@@ -20,25 +20,35 @@ func (h *Halo) MassEnclosed(bt BiasType, r float64) float64 {
 	case Biased:
 		return h.MassEnclosed(Corrected, r) / h.BFrac(r)
 	case Corrected:
-		switch h.mpt {
-		case NFW:
-			x := r / h.Rs 
-			return h.C200.M * (mNFW(x) / mNFW(h.C200.C))
-		}
-		panic("Given unrecognized MassProfileType.")
+		return h.model.m(r)
 	}
 	panic("Given unrecognized BiasType.")
 }
 
 // OverdensityRadius calculates the radius at which h has an average density
-// of rho.
+// of rho. OverdensityRadius panics if no such radius can be found.
 func (h *Halo) OverdensityRadius(bt BiasType, rho float64) float64 {
+	r, err := h.overdensityRadius(bt, rho)
+	if err != nil {
+		panic(err.Error())
+	}
+	return r
+}
+
+func (h *Halo) overdensityRadius(bt BiasType, rho float64) (float64, error) {
+	r, _, err := h.solveOverdensityRadius("overdensity radius", bt, rho)
+	return r, err
+}
+
+// solveOverdensityRadius is overdensityRadius with a custom label for the
+// search. The search starts from R200c, which must already be set.
+func (h *Halo) solveOverdensityRadius(quantity string, bt BiasType, rho float64) (float64, RootDiagnostics, error) {
 	radiusToRho := func(r float64) float64 {
 		m := h.MassEnclosed(bt, r)
 		return haloDensity(r, m)
 	}
 
-	return num.FindEqualConst(radiusToRho, rho, h.Rs, h.Rs)
+	return findEqualConst(quantity, radiusToRho, rho, h.C200.R)
 }
 
 // Acceleration computes the acceleration due to gravity of point charge
@@ -51,30 +61,43 @@ func acceleration(h *Halo, bt BiasType, r float64) float64 {
 
 // Rho calcualtes the density of the halo's dark matter at radius r. The
 // returned value is in cosmological units.
+//
+// The biased density is found from d M_bias / dr, where
+// M_bias = M_true / b. dM_true/dr is computed analytically. d ln b / d ln r
+// is computed analytically from the slopes and curvatures of f_th and of
+// the thermal pressure if h's f_th model is an FThermalCurvatureModel and
+// its pressure profile is a PressureCurvatureProfile, as all the built-in
+// models are, and numerically otherwise.
 func (h *Halo) Rho(bt BiasType, r float64) float64 {
 	switch bt {
 	case Biased:
-		m := func(r float64) float64 { return h.MassEnclosed(bt, r) }
-		return num.Derivative(m, r)(r) / (4 * math.Pi * r * r)
+		mTrue := h.MassEnclosed(Corrected, r)
+		dLnMdLnR := 4 * math.Pi * r * r * r * h.model.rho(r) / mTrue
+		var dLnBdLnR float64
+		if h.dLnBFrac != nil {
+			dLnBdLnR = h.dLnBFrac(r)
+		} else {
+			dLnBdLnR = num.Derivative(h.BFrac, r)(r) * r / h.BFrac(r)
+		}
+
+		mBias := mTrue / h.BFrac(r)
+		return mBias * (dLnMdLnR - dLnBdLnR) / (4 * math.Pi * r * r * r)
 	case Corrected:
-		rH, mH, c := h.C500.R, h.C500.M, h.C500.C
-		ampl := mH * c * c * c / (4.0 * math.Pi * rH * rH * rH * mNFW(c))
-		x := r / h.Rs
-		return ampl / (x * (1.0 + x) * (1.0 + x))
+		return h.model.rho(r)
 	}
 	panic("Unrecognized BiasType.")
 }
 
 // RhoGas returns the gas density at a given distance from the center of
 // the halo. The returned value is in cosmological units.
-func (h *Halo) RhoGas(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, r float64) float64 {
-	rho := -h.DPdr(pbt, ppt, AllPressure, r) / acceleration(h, bt, r)
+func (h *Halo) RhoGas(bt BiasType, pbt PressureBiasType, pp PressureProfile, r float64) float64 {
+	rho := -h.DPdr(pbt, pp, AllPressure, r) / acceleration(h, bt, r)
 	return rho / cosmo.MSunMks * math.Pow(cosmo.MpcMks, 3.0)
 }
 
 // GasEnclosed returns the mass of all the gas in a halo enclosed within the
 // given radius. The returned value is given in MSolar.
-func (h *Halo) GasEnclosed(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, r float64) float64 {
-	rho := func(r float64) float64 { return h.RhoGas(bt, pbt, ppt, r) }
+func (h *Halo) GasEnclosed(bt BiasType, pbt PressureBiasType, pp PressureProfile, r float64) float64 {
+	rho := func(r float64) float64 { return h.RhoGas(bt, pbt, pp, r) }
 	return num.Integral(rho, h.MinR(), 0.1, num.Log, num.Spherical)(r)
 }
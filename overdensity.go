@@ -0,0 +1,165 @@
+package halo
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// DensityReference is a flag corresponding to the background density that an
+// overdensity definition is measured relative to.
+type DensityReference int
+
+const (
+	// CriticalDensity overdensities are relative to the critical density.
+	CriticalDensity DensityReference = iota
+	// AverageDensity overdensities are relative to the average matter
+	// density.
+	AverageDensity
+	// VirialDensity overdensities are relative to the critical density, with
+	// the redshift-dependent overdensity of Bryan & Norman (1998), which is
+	// only calibrated for flat universes with a cosmological constant and for
+	// universes without dark energy. Delta is ignored.
+	VirialDensity
+)
+
+// DensityType specifies a spherical overdensity definition: a halo's radius
+// is the radius at which its average enclosed density is Delta times the
+// reference density.
+type DensityType struct {
+	Delta float64
+	Ref   DensityReference
+}
+
+var (
+	A200  = DensityType{200, AverageDensity}
+	A500  = DensityType{500, AverageDensity}
+	C200  = DensityType{200, CriticalDensity}
+	C500  = DensityType{500, CriticalDensity}
+	C2500 = DensityType{2500, CriticalDensity}
+
+	Virial = DensityType{Ref: VirialDensity}
+)
+
+// Validate returns an error if d is not a valid overdensity definition.
+func (d DensityType) Validate() error {
+	switch d.Ref {
+	case CriticalDensity, AverageDensity:
+		if d.Delta <= 0 {
+			return &ParameterError{"DensityType", "Delta", d.Delta, "Delta > 0"}
+		}
+		return nil
+	case VirialDensity:
+		return nil
+	}
+	return &EnumError{Type: "DensityReference", Value: int(d.Ref)}
+}
+
+// String returns the conventional label for d, e.g. "200c" or "vir".
+func (d DensityType) String() string {
+	switch d.Ref {
+	case CriticalDensity:
+		return fmt.Sprintf("%gc", d.Delta)
+	case AverageDensity:
+		return fmt.Sprintf("%gm", d.Delta)
+	case VirialDensity:
+		return "vir"
+	}
+	return fmt.Sprintf("DensityType{%g, %d}", d.Delta, d.Ref)
+}
+
+// Density returns the average density enclosed by a halo's boundary under
+// the overdensity definition d at redshift z in the cosmology c. The
+// returned value is in cosmological units. d must be valid.
+func (d DensityType) Density(c cosmo.Cosmology, z float64) float64 {
+	switch d.Ref {
+	case CriticalDensity:
+		return d.Delta * c.RhoCritical(z)
+	case AverageDensity:
+		return d.Delta * c.RhoAverage(z)
+	case VirialDensity:
+		return bryanNormanDelta(c, z) * c.RhoCritical(z)
+	}
+	panic("Given unrecognized DensityReference.")
+}
+
+// bryanNormanDelta returns the virial overdensity relative to the critical
+// density given by Bryan & Norman (1998). Their fit for universes without
+// dark energy is used if c.OmegaL is zero, and their fit for flat universes
+// with a cosmological constant is used otherwise. Curved universes with dark
+// energy and dark energy with w != -1 are outside of both fits; they are
+// given the flat fit, which depends on c only through OmegaM(z).
+func bryanNormanDelta(c cosmo.Cosmology, z float64) float64 {
+	e := c.HubbleFrac(z)
+	x := c.OmegaM*math.Pow(1+z, 3)/(e*e) - 1
+	if c.OmegaL == 0 {
+		return 18*math.Pi*math.Pi + 60*x - 32*x*x
+	}
+	return 18*math.Pi*math.Pi + 82*x - 39*x*x
+}
+
+type densityKey struct {
+	bt BiasType
+	d  DensityType
+}
+
+// densityCache stores the DensityInfo of overdensity definitions which do
+// not have dedicated fields in Halo.
+type densityCache struct {
+	mtx   sync.Mutex
+	infos map[densityKey]DensityInfo
+}
+
+// DensityInfo returns the mass, radius, and concentration of h under the
+// overdensity definition d. Here, concentration is the ratio of the radius to
+// h.Rs. bt specifies whether these quantities are computed from the true
+// mass profile or from the hydrostatic mass profile. Values for definitions
+// which do not have dedicated fields in Halo are computed when first
+// requested and are cached afterwards.
+func (h *Halo) DensityInfo(bt BiasType, d DensityType) (DensityInfo, error) {
+	if err := d.Validate(); err != nil {
+		return DensityInfo{}, err
+	}
+
+	switch bt {
+	case Corrected:
+		switch d {
+		case A200:
+			return h.A200, nil
+		case C200:
+			return h.C200, nil
+		case C500:
+			return h.C500, nil
+		}
+	case Biased:
+		if d == C500 {
+			return DensityInfo{h.M500cBias, h.R500cBias, h.R500cBias / h.Rs}, nil
+		}
+	default:
+		return DensityInfo{}, &EnumError{Type: "BiasType", Value: int(bt)}
+	}
+
+	key := densityKey{bt, d}
+
+	h.densityCache.mtx.Lock()
+	defer h.densityCache.mtx.Unlock()
+
+	if info, ok := h.densityCache.infos[key]; ok {
+		return info, nil
+	}
+
+	rho := d.Density(h.cosmology, h.Z)
+	r, err := h.overdensityRadius(bt, rho)
+	if err != nil {
+		return DensityInfo{}, err
+	}
+	info := DensityInfo{haloMass(r, rho), r, r / h.Rs}
+
+	if h.densityCache.infos == nil {
+		h.densityCache.infos = make(map[densityKey]DensityInfo)
+	}
+	h.densityCache.infos[key] = info
+	return info, nil
+}
@@ -0,0 +1,127 @@
+package halo
+
+import (
+	"sort"
+	"sync"
+
+	"bitbucket.org/phil-mansfield/halo/cosmo"
+)
+
+// PressureScale contains the properties of a halo which a PressureProfile
+// is evaluated against. M500c is in M_sun and R500c is in Mpc. If the
+// profile requires biased masses, M500c and R500c are the hydrostatic
+// (biased) mass and radius of the halo. Cosmology is the cosmology that the
+// halo was constructed in.
+type PressureScale struct {
+	M500c, R500c, Z float64
+	Cosmology       cosmo.Cosmology
+}
+
+// PressureProfile is a model of a halo's radial electron pressure profile.
+// All the built-in PressureProfileType flags implement it, and user-defined
+// profiles can be passed anywhere a PressureProfileType is accepted.
+//
+// If a PressureProfile also has a method with the signature
+// Validate() error, it will be called when a Halo is constructed.
+//
+// Implementations must be safe for concurrent use, since a Halo's methods
+// may be called from multiple goroutines at once.
+type PressureProfile interface {
+	// ElectronPressure returns the electron pressure in Pa at a distance r
+	// in Mpc from the center of a halo with the given scale.
+	ElectronPressure(s PressureScale, r float64) float64
+	// RequiresBiasedMass returns true if the profile is a function of
+	// biased (i.e. hydrostatic) halo masses and false if it is a function
+	// of true masses.
+	RequiresBiasedMass() bool
+}
+
+// PressureDerivativeProfile is a PressureProfile which can compute the radial
+// derivative of its electron pressure analytically. Halo.DPdr will use
+// DElectronPressureDr in place of a numerical derivative when it is
+// available.
+type PressureDerivativeProfile interface {
+	PressureProfile
+	// DElectronPressureDr returns the derivative of ElectronPressure with
+	// respect to r in Pa / Mpc.
+	DElectronPressureDr(s PressureScale, r float64) float64
+}
+
+// PressureCurvatureProfile is a PressureDerivativeProfile which can also
+// compute d^2 ln P_e / d ln r^2 analytically. Every PressureProfileType and
+// GNFWPressure is a PressureCurvatureProfile. If a Halo's f_th model is an
+// FThermalCurvatureModel, its biased density is found without numerical
+// derivatives.
+type PressureCurvatureProfile interface {
+	PressureDerivativeProfile
+	// D2LnElectronPressureDLnR2 returns the second derivative of
+	// ln(ElectronPressure) with respect to ln(r).
+	D2LnElectronPressureDLnR2(s PressureScale, r float64) float64
+}
+
+type pressureProfileValidator interface {
+	Validate() error
+}
+
+var pressureRegistry = struct {
+	mtx      sync.RWMutex
+	profiles map[string]PressureProfile
+}{profiles: map[string]PressureProfile{}}
+
+// RegisterPressureProfile makes pp available under the given name through
+// LookupPressureProfile. It returns an error if pp is nil, is invalid, or if
+// the name is already in use. It is safe to call concurrently.
+func RegisterPressureProfile(name string, pp PressureProfile) error {
+	if err := validatePressureProfile(pp); err != nil {
+		return err
+	}
+
+	pressureRegistry.mtx.Lock()
+	defer pressureRegistry.mtx.Unlock()
+
+	if _, ok := pressureRegistry.profiles[name]; ok {
+		return &RegistryError{name, true}
+	}
+	pressureRegistry.profiles[name] = pp
+	return nil
+}
+
+// LookupPressureProfile returns the PressureProfile registered under the
+// given name. The built-in PressureProfileTypes are registered under the
+// names of their constants, e.g. "Planck2012".
+func LookupPressureProfile(name string) (PressureProfile, error) {
+	pressureRegistry.mtx.RLock()
+	defer pressureRegistry.mtx.RUnlock()
+
+	pp, ok := pressureRegistry.profiles[name]
+	if !ok {
+		return nil, &RegistryError{name, false}
+	}
+	return pp, nil
+}
+
+// PressureProfileNames returns the names of all registered pressure profiles
+// in sorted order.
+func PressureProfileNames() []string {
+	pressureRegistry.mtx.RLock()
+	defer pressureRegistry.mtx.RUnlock()
+
+	names := make([]string, 0, len(pressureRegistry.profiles))
+	for name := range pressureRegistry.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validatePressureProfile returns an error if pp is nil or if it reports
+// itself as invalid.
+func validatePressureProfile(pp PressureProfile) error {
+	if pp == nil {
+		return &NilArgumentError{"PressureProfile"}
+	}
+	if v, ok := pp.(pressureProfileValidator); ok {
+		return v.Validate()
+	}
+	return nil
+}
@@ -1,17 +1,21 @@
 package halo
 
 import (
+	"fmt"
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/halo/num"
 )
 
-// PressureProfileType is a flag corresponds to the fit used to derive a
-// radial pressure function. When calcualting h.Pressure(), the profile may
-// either require that h.M500cBias and h.R500cBias are initialized or that
-// h.C500.M and h.C500.R are initialized. The required type can be found
-// with the ppt.RequiresBias() method.
+// PressureProfileType is a flag corresponding to one of the built-in fits
+// used to derive a radial pressure function. Every PressureProfileType is a
+// PressureProfile.
+//
+// The cosmo-OWLS AGN-heating profiles of Le Brun et al. (2015) are not
+// built in yet: their fitted parameters still need to be transcribed from
+// the paper's tables. Until then they can be used through GNFWPressure,
+// with BiasedMass = false since they were fit against true masses.
 type PressureProfileType int
 
 const (
@@ -19,6 +23,16 @@ const (
 	Arnaud2009
 	BattagliaAGN2012
 	BattagliaShockHeating2012
+	Nagai2007
+	Sayers2013
+	McDonald2014LowZ
+	McDonald2014HighZ
+	Arnaud2009CoolCore
+	Arnaud2009Disturbed
+	Planck2012CoolCore
+	Planck2012Disturbed
+
+	pressureProfileTypeCount
 )
 
 // PressurePopulationType is a flag that corresponds to the population of
@@ -49,28 +63,10 @@ const (
 )
 
 const (
-	planckPivotM500H float64 = 3e14
-	planckA0kev             = 1.65e-3
-
-	planckP0   = 6.41
-	planckC500 = 1.81
-
-	planckAlpha = 1.33
-	planckBeta  = 4.13
-	planckGamma = 0.31
-	planckDelta = 0.12
-
-	arnaudPivotM500H = 3e14
+	arnaudPivotM500H float64 = 3e14
 	arnaudA0kev = 1.65e-3
 	arnaudAP = 0.12
 
-	arnaudP0   = 8.403
-	arnaudC500 = 1.177
-
-	arnaudAlpha  = 1.051
-	arnaudBeta = 5.4905
-	arnaudGamma = 0.3081
-
 	battagliaPMassPivot = 1e14
 
 	battagliaPGamma = -0.3
@@ -101,171 +97,394 @@ const (
 	battagliaBzShockHeating = 0.535
 )
 
+// arnaudShape contains the shape parameters of an Arnaud et al. (2010)
+// profile. P0 is in units of h70^-3/2.
+type arnaudShape struct {
+	P0, C500           float64
+	Alpha, Beta, Gamma float64
+}
+
 var (
-	// This needs to be multiplied by rhoCrit * m500c / r500c.
-	pDeltaBattagliaPre = cosmo.GMks * 250 * (cosmo.OmegaB / cosmo.OmegaM) *
+	// Full, cool-core, and morphologically disturbed REXCESS samples.
+	arnaud2009Full = arnaudShape{8.403, 1.177, 1.051, 5.4905, 0.3081}
+	arnaud2009CoolCore = arnaudShape{3.249, 1.128, 1.2223, 5.4905, 0.7736}
+	arnaud2009Disturbed = arnaudShape{3.202, 1.083, 1.4063, 5.4905, 0.3798}
+
+	planck2012GNFW = GNFWPressure{
+		P0: 6.41, C500: 1.81,
+		Alpha: 1.33, Beta: 4.13, Gamma: 0.31,
+		MassExponent: 2.0/3.0 + 0.12, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+	// Cool-core and morphologically disturbed sub-samples of the Planck
+	// Intermediate Results V clusters.
+	planck2012CoolCoreGNFW = GNFWPressure{
+		P0: 11.82, C500: 0.60,
+		Alpha: 0.76, Beta: 6.58, Gamma: 0.31,
+		MassExponent: 2.0/3.0 + 0.12, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+	planck2012DisturbedGNFW = GNFWPressure{
+		P0: 4.72, C500: 2.19,
+		Alpha: 1.82, Beta: 3.62, Gamma: 0.31,
+		MassExponent: 2.0/3.0 + 0.12, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+
+	// Fit to non-radiative and cooling simulations, so true masses are
+	// used. Nagai, Kravtsov, & Vikhlinin (2007) quote P0 = 3.3 relative to
+	// the total gas pressure P500 = 1.45e-11 (M500c / 1e15 h^-1 M_sun)^(2/3)
+	// E(z)^(8/3) erg cm^-3 with h = 0.7. This is 1.938 times the Arnaud et
+	// al. (2010) P500 used here, which is an electron pressure, and converting
+	// gas pressure to electron pressure multiplies by mu / mu_e = 0.518. P0
+	// is therefore 3.3 * 1.938 * 0.518 = 3.31.
+	nagai2007GNFW = GNFWPressure{
+		P0: 3.31, C500: 1.8,
+		Alpha: 1.3, Beta: 4.3, Gamma: 0.7,
+		MassExponent: 2.0/3.0, EzExponent: 8.0/3.0,
+		BiasedMass: false,
+	}
+
+	// Bolocam SZ profiles of clusters with X-ray hydrostatic masses.
+	sayers2013GNFW = GNFWPressure{
+		P0: 4.29, C500: 1.18,
+		Alpha: 0.86, Beta: 3.67, Gamma: 0.67,
+		MassExponent: 2.0/3.0 + 0.12, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+
+	// Chandra profiles of SPT clusters at 0.3 < z < 0.6 and 0.6 < z < 1.2,
+	// with masses calibrated from the X-ray Y_X relation.
+	mcDonald2014LowZGNFW = GNFWPressure{
+		P0: 4.33, C500: 2.59,
+		Alpha: 1.63, Beta: 3.30, Gamma: 0.26,
+		MassExponent: 2.0/3.0, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+	mcDonald2014HighZGNFW = GNFWPressure{
+		P0: 3.47, C500: 2.59,
+		Alpha: 2.27, Beta: 3.48, Gamma: 0.15,
+		MassExponent: 2.0/3.0, EzExponent: 8.0/3.0,
+		BiasedMass: true,
+	}
+
+	// This needs to be multiplied by rhoCrit * (OmegaB / OmegaM) *
+	// m500c / r500c.
+	pDeltaBattagliaPre = cosmo.GMks * 250 *
 		math.Pow(cosmo.MSunMks, 2.0) / math.Pow(cosmo.MpcMks, 4.0)
 )
 
+var pressureProfileTypeNames = [pressureProfileTypeCount]string{
+	"Planck2012",
+	"Arnaud2009",
+	"BattagliaAGN2012",
+	"BattagliaShockHeating2012",
+	"Nagai2007",
+	"Sayers2013",
+	"McDonald2014LowZ",
+	"McDonald2014HighZ",
+	"Arnaud2009CoolCore",
+	"Arnaud2009Disturbed",
+	"Planck2012CoolCore",
+	"Planck2012Disturbed",
+}
+
+func init() {
+	for ppt := PressureProfileType(0); ppt < pressureProfileTypeCount; ppt++ {
+		if err := RegisterPressureProfile(ppt.String(), ppt); err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// String returns the name of ppt.
+func (ppt PressureProfileType) String() string {
+	if ppt < 0 || ppt >= pressureProfileTypeCount {
+		return fmt.Sprintf("PressureProfileType(%d)", int(ppt))
+	}
+	return pressureProfileTypeNames[ppt]
+}
+
+// Validate returns an error if ppt is not a recognized PressureProfileType.
+func (ppt PressureProfileType) Validate() error {
+	if ppt < 0 || ppt >= pressureProfileTypeCount {
+		return &EnumError{Type: "PressureProfileType", Value: int(ppt)}
+	}
+	return nil
+}
+
+// RequiresBiasedMass returns true if ppt was fit against biased (i.e.
+// hydrostatic) halo masses and false if it was fit against true masses.
 func (ppt PressureProfileType) RequiresBiasedMass() bool {
 	switch ppt {
-	case Planck2012:
-		return true
-	case Arnaud2009:
+	case Arnaud2009, Arnaud2009CoolCore, Arnaud2009Disturbed:
 		return true
 	case BattagliaAGN2012:
 		return false
 	case BattagliaShockHeating2012:
 		return false
 	}
-	panic("Given unrecognized PressureProfileType")
+	return ppt.gnfw().BiasedMass
 }
 
-func pThermal(h *Halo, ppt PressureProfileType, pt PressurePopulationType, r float64) float64 {
+// gnfw returns the GNFWPressure corresponding to ppt. It panics if ppt is not
+// one of the GNFW profiles.
+func (ppt PressureProfileType) gnfw() GNFWPressure {
+	switch ppt {
+	case Planck2012:
+		return planck2012GNFW
+	case Planck2012CoolCore:
+		return planck2012CoolCoreGNFW
+	case Planck2012Disturbed:
+		return planck2012DisturbedGNFW
+	case Nagai2007:
+		return nagai2007GNFW
+	case Sayers2013:
+		return sayers2013GNFW
+	case McDonald2014LowZ:
+		return mcDonald2014LowZGNFW
+	case McDonald2014HighZ:
+		return mcDonald2014HighZGNFW
+	}
+	panic("Given unrecognized PressureProfileType.")
+}
 
-	type battagliaParam func(m500c, z float64) float64
-	makeBattagliaParam := func(a0, am, az float64) battagliaParam {
-		return func(m500c, z float64) float64 {
-			return a0 * math.Pow(1 + z, az) *
-				math.Pow(m500c/battagliaPMassPivot, am)
-		}
+// ElectronPressure returns the electron pressure in Pa at a distance r from
+// the center of a halo with the given scale.
+func (ppt PressureProfileType) ElectronPressure(s PressureScale, r float64) float64 {
+	switch ppt {
+	case Arnaud2009:
+		return arnaudPressure(arnaud2009Full, s, r)
+	case Arnaud2009CoolCore:
+		return arnaudPressure(arnaud2009CoolCore, s, r)
+	case Arnaud2009Disturbed:
+		return arnaudPressure(arnaud2009Disturbed, s, r)
+	case BattagliaAGN2012:
+		return battagliaPressure(battagliaAGN, s, r)
+	case BattagliaShockHeating2012:
+		return battagliaPressure(battagliaShockHeating, s, r)
 	}
+	return ppt.gnfw().ElectronPressure(s, r)
+}
 
-	pDeltaBattaglia := pDeltaBattagliaPre * cosmo.RhoCritical(h.Z) *
-		h.C500.M / h.C500.R
+// DElectronPressureDr returns the derivative of ElectronPressure with respect
+// to r in Pa / Mpc. It is computed analytically.
+func (ppt PressureProfileType) DElectronPressureDr(s PressureScale, r float64) float64 {
+	return ppt.ElectronPressure(s, r) * ppt.dLnPdLnR(s, r) / r
+}
 
-	switch pt {
-	case AllPressure:
-		muFrac := cosmo.Mu / cosmo.ElectronMu
-		return pThermal(h, ppt, ElectronPressure, r) * muFrac
-	case ElectronPressure:
-		switch ppt {
-		case Planck2012:
-			// This is a measured fit, so we need to use the biased mass.
-			mFrac := h.M500cBias / (planckPivotM500H / cosmo.H70)
-			x := r / h.R500cBias
-			y := planckC500 * x
+// dLnPdLnR returns the logarithmic slope of the electron pressure profile.
+func (ppt PressureProfileType) dLnPdLnR(s PressureScale, r float64) float64 {
+	switch ppt {
+	case Arnaud2009:
+		return arnaudLogSlope(arnaud2009Full, s, r)
+	case Arnaud2009CoolCore:
+		return arnaudLogSlope(arnaud2009CoolCore, s, r)
+	case Arnaud2009Disturbed:
+		return arnaudLogSlope(arnaud2009Disturbed, s, r)
+	case BattagliaAGN2012:
+		return battagliaLogSlope(battagliaAGN, s, r)
+	case BattagliaShockHeating2012:
+		return battagliaLogSlope(battagliaShockHeating, s, r)
+	}
+	return ppt.gnfw().dLnPdLnR(s, r)
+}
 
-			scaledPressure := planckP0 / (math.Pow(y, planckGamma) *
-				math.Pow(1.0+math.Pow(y, planckAlpha),
-					(planckBeta - planckGamma)/planckAlpha))
+// D2LnElectronPressureDLnR2 returns d^2 ln P_e / d ln r^2. It is computed
+// analytically.
+func (ppt PressureProfileType) D2LnElectronPressureDLnR2(s PressureScale, r float64) float64 {
+	switch ppt {
+	case Arnaud2009:
+		return arnaudLogCurvature(arnaud2009Full, s, r)
+	case Arnaud2009CoolCore:
+		return arnaudLogCurvature(arnaud2009CoolCore, s, r)
+	case Arnaud2009Disturbed:
+		return arnaudLogCurvature(arnaud2009Disturbed, s, r)
+	case BattagliaAGN2012:
+		return battagliaLogCurvature(battagliaAGN, s, r)
+	case BattagliaShockHeating2012:
+		return battagliaLogCurvature(battagliaShockHeating, s, r)
+	}
+	return ppt.gnfw().D2LnElectronPressureDLnR2(s, r)
+}
 
-			P500 := (planckA0kev * math.Pow(mFrac, 2.0/3.0 + 0.12) *
-				math.Pow(cosmo.HubbleFrac(h.Z), 8.0/3.0) *
-				(cosmo.H70 * cosmo.H70))
+// arnaudPressure returns the electron pressure in Pa of an Arnaud et al.
+// (2010) profile, including the radially varying mass-scaling term.
+func arnaudPressure(a arnaudShape, s PressureScale, r float64) float64 {
+	mFrac := s.M500c / (arnaudPivotM500H / s.Cosmology.H70())
+	x := r / s.R500c
+	y := a.C500 * x
 
-			PkeV := P500 * scaledPressure
-			return PkeV * kevToPascal
+	app := 0.1 - (arnaudAP + 0.1) * math.Pow(x/2, 3.0) /
+		(1 + math.Pow(x/2, 3.0))
 
-		case Arnaud2009:
+	scaledPressure := + math.Pow(mFrac, arnaudAP + app) *
+		math.Pow(s.Cosmology.H70(), -1.5) *
+		a.P0 / (math.Pow(y, a.Gamma) *
+		math.Pow(1 + math.Pow(y, a.Alpha),
+		(a.Beta - a.Gamma)/a.Alpha))
 
-			mFrac := h.M500cBias / (arnaudPivotM500H / cosmo.H70)
-			x := r / h.R500cBias
-			y := arnaudC500 * x
+	P500 := (arnaudA0kev * math.Pow(mFrac, 2.0/3.0) *
+		math.Pow(s.Cosmology.HubbleFrac(s.Z), 8.0/3.0) *
+		(s.Cosmology.H70() * s.Cosmology.H70()))
 
-			app := 0.1 - (arnaudAP + 0.1) * math.Pow(x/2, 3.0) /
-				(1 + math.Pow(x/2, 3.0))
+	PkeV := P500 * scaledPressure
+	return PkeV * kevToPascal
+}
 
-			scaledPressure := + math.Pow(mFrac, arnaudAP + app) *
-				math.Pow(cosmo.H70, -1.5) *
-				arnaudP0 / (math.Pow(y, arnaudGamma) *
-				math.Pow(1 + math.Pow(y, arnaudAlpha),
-				(arnaudBeta - arnaudGamma)/arnaudAlpha))
+// arnaudLogSlope returns the logarithmic slope of arnaudPressure.
+func arnaudLogSlope(a arnaudShape, s PressureScale, r float64) float64 {
+	mFrac := s.M500c / (arnaudPivotM500H / s.Cosmology.H70())
+	x := r / s.R500c
+	u := math.Pow(x/2, 3.0)
+	// x d(alpha'_P)/dx
+	dApp := -(arnaudAP + 0.1) * 3 * u / ((1 + u) * (1 + u))
 
-			P500 := (arnaudA0kev * math.Pow(mFrac, 2.0/3.0) *
-				math.Pow(cosmo.HubbleFrac(h.Z), 8.0/3.0) *
-				(cosmo.H70 * cosmo.H70))
+	return dApp*math.Log(mFrac) +
+		gnfwLogSlope(a.C500*x, a.Alpha, a.Beta, a.Gamma)
+}
 
-			PkeV := P500 * scaledPressure
-			return PkeV * kevToPascal
+// arnaudLogCurvature returns the derivative of arnaudLogSlope with respect
+// to ln(r).
+func arnaudLogCurvature(a arnaudShape, s PressureScale, r float64) float64 {
+	mFrac := s.M500c / (arnaudPivotM500H / s.Cosmology.H70())
+	x := r / s.R500c
+	u := math.Pow(x/2, 3.0)
+	// x d/dx (x d(alpha'_P)/dx)
+	d2App := -(arnaudAP + 0.1) * 9 * u * (1 - u) / ((1 + u) * (1 + u) * (1 + u))
+
+	return d2App*math.Log(mFrac) +
+		gnfwLogCurvature(a.C500*x, a.Alpha, a.Beta, a.Gamma)
+}
 
+// battagliaShape contains the parameters of a Battaglia et al. (2012)
+// profile. Each parameter is given as {A0, Am, Az}, and scales as
+// A0 (M500c / 1e14 M_sun)^Am (1 + z)^Az.
+type battagliaShape struct {
+	P0, Xc, Beta [3]float64
+}
 
-		case BattagliaAGN2012:
-			x := r / h.C500.R
+var (
+	battagliaAGN = battagliaShape{
+		P0:   [3]float64{battagliaP0AGN, battagliaPmAGN, battagliaPzAGN},
+		Xc:   [3]float64{battagliaX0AGN, battagliaXmAGN, battagliaXzAGN},
+		Beta: [3]float64{battagliaB0AGN, battagliaBmAGN, battagliaBzAGN},
+	}
+	battagliaShockHeating = battagliaShape{
+		P0: [3]float64{battagliaP0ShockHeating,
+			battagliaPmShockHeating, battagliaPzShockHeating},
+		Xc: [3]float64{battagliaX0ShockHeating,
+			battagliaXmShockHeating, battagliaXzShockHeating},
+		Beta: [3]float64{battagliaB0ShockHeating,
+			battagliaBmShockHeating, battagliaBzShockHeating},
+	}
+)
 
-			p0 := makeBattagliaParam(battagliaP0AGN,
-				battagliaPmAGN,
-				battagliaPzAGN)
-			xc := makeBattagliaParam(battagliaX0AGN,
-				battagliaXmAGN,
-				battagliaXzAGN)
-			beta := makeBattagliaParam(battagliaB0AGN,
-				battagliaBmAGN,
-				battagliaBzAGN)
+func battagliaParam(p [3]float64, m500c, z float64) float64 {
+	return p[0] * math.Pow(1 + z, p[2]) *
+		math.Pow(m500c/battagliaPMassPivot, p[1])
+}
 
-			xFrac := x / xc(h.C500.M, h.Z)
+// battagliaPressure returns the electron pressure in Pa of a Battaglia et
+// al. (2012) profile.
+func battagliaPressure(b battagliaShape, s PressureScale, r float64) float64 {
+	c := s.Cosmology
+	pDeltaBattaglia := pDeltaBattagliaPre * c.RhoCritical(s.Z) *
+		(c.OmegaB / c.OmegaM) * s.M500c / s.R500c
 
-			muFrac := cosmo.Mu / cosmo.ElectronMu
+	x := r / s.R500c
+	xFrac := x / battagliaParam(b.Xc, s.M500c, s.Z)
 
-			return p0(h.C500.M, h.Z) * math.Pow(xFrac, battagliaPGamma) *
-				math.Pow(1.0 + math.Pow(xFrac, battagliaPAlpha),
-				-beta(h.C500.M, h.Z)) * pDeltaBattaglia / muFrac
+	muFrac := c.Mu() / c.ElectronMu()
 
-		case BattagliaShockHeating2012:
-			x := r / h.C500.R
+	return battagliaParam(b.P0, s.M500c, s.Z) *
+		math.Pow(xFrac, battagliaPGamma) *
+		math.Pow(1.0 + math.Pow(xFrac, battagliaPAlpha),
+		-battagliaParam(b.Beta, s.M500c, s.Z)) * pDeltaBattaglia / muFrac
+}
 
-			p0 := makeBattagliaParam(battagliaP0ShockHeating,
-				battagliaPmShockHeating,
-				battagliaPzShockHeating)
-			xc := makeBattagliaParam(battagliaX0ShockHeating,
-				battagliaXmShockHeating,
-				battagliaXzShockHeating)
-			beta := makeBattagliaParam(battagliaB0ShockHeating,
-				battagliaBmShockHeating,
-				battagliaBzShockHeating)
+// battagliaLogSlope returns the logarithmic slope of battagliaPressure.
+func battagliaLogSlope(b battagliaShape, s PressureScale, r float64) float64 {
+	xFrac := r / s.R500c / battagliaParam(b.Xc, s.M500c, s.Z)
+	xa := math.Pow(xFrac, battagliaPAlpha)
+	return battagliaPGamma - battagliaParam(b.Beta, s.M500c, s.Z) *
+		battagliaPAlpha * xa / (1 + xa)
+}
 
-			xFrac := x / xc(h.C500.M, h.Z)
+// battagliaLogCurvature returns the derivative of battagliaLogSlope with
+// respect to ln(r).
+func battagliaLogCurvature(b battagliaShape, s PressureScale, r float64) float64 {
+	xFrac := r / s.R500c / battagliaParam(b.Xc, s.M500c, s.Z)
+	xa := math.Pow(xFrac, battagliaPAlpha)
+	return -battagliaParam(b.Beta, s.M500c, s.Z) *
+		battagliaPAlpha * battagliaPAlpha * xa / ((1 + xa) * (1 + xa))
+}
 
-			muFrac := cosmo.Mu / cosmo.ElectronMu
+// pressureScale returns the scale which the pressure profile pp should be
+// evaluated at for the given PressureBiasType.
+func (h *Halo) pressureScale(pbt PressureBiasType, pp PressureProfile) PressureScale {
+	if pp.RequiresBiasedMass() && pbt != NaiveThermalPressure {
+		return PressureScale{h.M500cBias, h.R500cBias, h.Z, h.cosmology}
+	}
+	return PressureScale{h.C500.M, h.C500.R, h.Z, h.cosmology}
+}
 
-			return p0(h.C500.M, h.Z) * math.Pow(xFrac, battagliaPGamma) *
-				math.Pow(1.0 + math.Pow(xFrac, battagliaPAlpha),
-				-beta(h.C500.M, h.Z)) * pDeltaBattaglia / muFrac
-		}
-		panic("Given unrecognized PressureProfileType.")
+func populationFactor(c cosmo.Cosmology, pt PressurePopulationType) float64 {
+	switch pt {
+	case AllPressure:
+		return c.Mu() / c.ElectronMu()
+	case ElectronPressure:
+		return 1
 	}
 	panic("Given unrecognized PressureType.")
 }
 
-// P returns either the electron pressure or effective pressure at a
-// distance r from the center of a halo.  The type of pressure profile
-// can also be specified, although only one has been coded up so far.
+// Pressure returns either the thermal pressure or effective pressure at a
+// distance r from the center of a halo, as predicted by the pressure profile
+// pp. Depending on pp.RequiresBiasedMass(), pp is evaluated with either
+// h.M500cBias and h.R500cBias or h.C500.M and h.C500.R, except for
+// NaiveThermalPressure, which always uses the latter.
 //
 // The returned pressure is in MKS units.
-func (h *Halo) Pressure(pbt PressureBiasType, ppt PressureProfileType, pt PressurePopulationType, r float64) float64 {
-	switch pbt {
-	case NaiveThermalPressure:
-		tmpR := h.R500cBias
-		tmpM := h.M500cBias
-
-		h.R500cBias = h.C500.R
-		h.M500cBias = h.C500.M
-
-		p := h.Pressure(ThermalPressure, ppt, pt, r)
-
-		h.R500cBias = tmpR
-		h.M500cBias = tmpM
+func (h *Halo) Pressure(pbt PressureBiasType, pp PressureProfile, pt PressurePopulationType, r float64) float64 {
+	s := h.pressureScale(pbt, pp)
+	p := pp.ElectronPressure(s, r) * populationFactor(h.cosmology, pt)
 
+	switch pbt {
+	case ThermalPressure, NaiveThermalPressure:
 		return p
-	case ThermalPressure:
-		return pThermal(h, ppt, pt, r)
 	case EffectivePressure:
-		return pThermal(h, ppt, pt, r) / h.FThermal(r)
+		return p / h.FThermal(r)
 	}
 	panic("Given unrecognized PressureBiasType.")
 }
 
 // DPdr calculates the derivative the halo's pressure profile with respect
-// to radius. The returned quantity is in MKS units.
-func (h *Halo) DPdr(pbt PressureBiasType, ppt PressureProfileType, pt PressurePopulationType, r float64) float64 {
-	p := func(r float64) float64 { return h.Pressure(pbt, ppt, pt, r) }
+// to radius. The derivative is computed analytically if pp is a
+// PressureDerivativeProfile and is computed numerically otherwise. The
+// derivative of the effective pressure, P_th / f_th, also uses h.BetaBias,
+// which is analytic if h's f_th model is an FThermalSlopeModel. The
+// returned quantity is in MKS units.
+func (h *Halo) DPdr(pbt PressureBiasType, pp PressureProfile, pt PressurePopulationType, r float64) float64 {
+	if dpp, ok := pp.(PressureDerivativeProfile); ok {
+		s := h.pressureScale(pbt, pp)
+		pop := populationFactor(h.cosmology, pt)
+		dPdr := dpp.DElectronPressureDr(s, r) * pop
+		if pbt == EffectivePressure {
+			p := pp.ElectronPressure(s, r) * pop
+			dPdr = (dPdr - p*h.BetaBias(r)/r) / h.FThermal(r)
+		}
+		return dPdr / cosmo.MpcMks
+	}
+
+	p := func(r float64) float64 { return h.Pressure(pbt, pp, pt, r) }
 	return num.Derivative(p, r)(r) / cosmo.MpcMks
 }
 
 // ThomsonY calculates the spherical Thompson Y out to the specified radius.
-func (h *Halo) ThompsonY(pbt PressureBiasType, ppt PressureProfileType, r float64) float64 {
+func (h *Halo) ThompsonY(pbt PressureBiasType, pp PressureProfile, r float64) float64 {
 	P := func(r float64) float64 {
-		return h.Pressure(pbt, ppt, ElectronPressure, r)
+		return h.Pressure(pbt, pp, ElectronPressure, r)
 	}
 
 	intTerm :=  num.Integral(P, h.MinR(), h.Rs, num.Log, num.Spherical)(r)
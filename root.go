@@ -0,0 +1,200 @@
+package halo
+
+import (
+	"math"
+
+	"bitbucket.org/phil-mansfield/halo/num"
+)
+
+const (
+	rootMaxIterations = 200
+	rootRelTol        = 1e-10
+
+	// The largest residual which a converged root may have, relative to the
+	// largest residual at the ends of its initial bracket. Larger residuals
+	// indicate that the bracket contained a discontinuity rather than a
+	// root.
+	rootMaxResidual = 1e-4
+
+	// The number of times that a search will widen its bracket by a factor
+	// of two before giving up.
+	rootSearchSteps = 16
+)
+
+// RootDiagnostics describes a root-finding search performed while
+// constructing a Halo. Iterations is the number of iterations of Brent's
+// method, Expansions is the number of times the initial bracket was widened
+// before it contained a root, [Lo, Hi] is the final bracket, and Residual is
+// the value of the search's residual function at the returned root.
+type RootDiagnostics struct {
+	Quantity   string
+	Iterations int
+	Expansions int
+	Lo, Hi     float64
+	Residual   float64
+}
+
+// findRoot finds a root of f within [lo, hi] using Brent's method. The
+// quantity string is used to label any returned errors. If f returns NaN at
+// any point the search is aborted with a ConvergenceError; callers which
+// evaluate failable functions inside of f should record their own errors
+// and return NaN.
+func findRoot(quantity string, f num.Func1D, lo, hi float64) (float64, RootDiagnostics, error) {
+	return brent(quantity, f, lo, hi, f(lo), f(hi))
+}
+
+// brent is findRoot for callers which have already evaluated f(lo) = fLo
+// and f(hi) = fHi.
+func brent(quantity string, f num.Func1D, lo, hi, fLo, fHi float64) (float64, RootDiagnostics, error) {
+	diag := RootDiagnostics{Quantity: quantity, Lo: lo, Hi: hi}
+
+	a, b := lo, hi
+	fa, fb := fLo, fHi
+	if math.IsNaN(fa) || math.IsNaN(fb) || fa*fb > 0 {
+		return math.NaN(), diag, &BracketError{quantity, lo, hi, fa, fb}
+	}
+	if fa == 0 {
+		diag.Hi = lo
+		return a, diag, nil
+	} else if fb == 0 {
+		diag.Lo = hi
+		return b, diag, nil
+	}
+	maxResidual := rootMaxResidual * math.Max(math.Abs(fa), math.Abs(fb))
+
+	c, fc := a, fa
+	d := b - a
+	e := d
+
+	for i := 0; i < rootMaxIterations; i++ {
+		if fb*fc > 0 {
+			c, fc = a, fa
+			d = b - a
+			e = d
+		}
+		if math.Abs(fc) < math.Abs(fb) {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+
+		tol := rootRelTol * math.Max(math.Abs(b), math.SmallestNonzeroFloat64)
+		m := (c - b) / 2
+		if math.Abs(m) <= tol || fb == 0 {
+			diag.Iterations = i
+			diag.Lo, diag.Hi = math.Min(b, c), math.Max(b, c)
+			diag.Residual = fb
+			if math.Abs(fb) > maxResidual {
+				return math.NaN(), diag, &ConvergenceError{
+					quantity, i, diag.Lo, diag.Hi, fb,
+				}
+			}
+			return b, diag, nil
+		}
+
+		if math.Abs(e) >= tol && math.Abs(fa) > math.Abs(fb) {
+			// Attempt inverse quadratic interpolation (or the secant
+			// method, if only two points are distinct).
+			var p, q float64
+			s := fb / fa
+			if a == c {
+				p = 2 * m * s
+				q = 1 - s
+			} else {
+				q0, r := fa/fc, fb/fc
+				p = s * (2*m*q0*(q0-r) - (b-a)*(r-1))
+				q = (q0 - 1) * (r - 1) * (s - 1)
+			}
+			if p > 0 {
+				q = -q
+			} else {
+				p = -p
+			}
+
+			if 2*p < math.Min(3*m*q-math.Abs(tol*q), math.Abs(e*q)) {
+				e, d = d, p/q
+			} else {
+				d, e = m, m
+			}
+		} else {
+			d, e = m, m
+		}
+
+		a, fa = b, fb
+		if math.Abs(d) > tol {
+			b += d
+		} else if m > 0 {
+			b += tol
+		} else {
+			b -= tol
+		}
+		fb = f(b)
+
+		if math.IsNaN(fb) {
+			diag.Iterations = i + 1
+			diag.Lo, diag.Hi = math.Min(b, c), math.Max(b, c)
+			diag.Residual = fb
+			return math.NaN(), diag, &ConvergenceError{
+				quantity, i + 1, diag.Lo, diag.Hi, fb,
+			}
+		}
+	}
+
+	diag.Iterations = rootMaxIterations
+	diag.Lo, diag.Hi = math.Min(b, c), math.Max(b, c)
+	diag.Residual = fb
+	return math.NaN(), diag, &ConvergenceError{
+		quantity, rootMaxIterations, diag.Lo, diag.Hi, fb,
+	}
+}
+
+// findEqual finds a value x for which f(x) = g(x), starting from the
+// bracket [lo, hi]. If [lo, hi] does not contain a solution, it is widened
+// by a factor of two at a time on the side with the smaller residual until
+// it does, so lo and hi must be positive.
+func findEqual(quantity string, f, g num.Func1D, lo, hi float64) (float64, RootDiagnostics, error) {
+	diff := func(x float64) float64 { return f(x) - g(x) }
+
+	fLo, fHi := diff(lo), diff(hi)
+	i := 0
+	for ; i < rootSearchSteps && fLo*fHi > 0; i++ {
+		if math.Abs(fLo) < math.Abs(fHi) {
+			lo /= 2
+			fLo = diff(lo)
+		} else {
+			hi *= 2
+			fHi = diff(hi)
+		}
+	}
+
+	x, diag, err := brent(quantity, diff, lo, hi, fLo, fHi)
+	diag.Expansions = i
+	return x, diag, err
+}
+
+// findEqualConst finds a value x for which f(x) = c, starting from an
+// initial guess. The search interval is repeatedly widened by a factor of two
+// in either direction until it brackets a solution. guess must be positive.
+func findEqualConst(quantity string, f num.Func1D, c, guess float64) (float64, RootDiagnostics, error) {
+	diff := func(x float64) float64 { return f(x) - c }
+
+	lo, hi := guess, guess
+	fLo, fHi := diff(lo), diff(hi)
+	for i := 0; i < rootSearchSteps; i++ {
+		lo, hi = lo/2, hi*2
+		fLo, fHi = diff(lo), diff(hi)
+
+		if math.IsNaN(fLo) || math.IsNaN(fHi) {
+			break
+		} else if fLo*fHi <= 0 {
+			x, diag, err := brent(quantity, diff, lo, hi, fLo, fHi)
+			diag.Expansions = i + 1
+			return x, diag, err
+		}
+	}
+
+	diag := RootDiagnostics{
+		Quantity: quantity, Expansions: rootSearchSteps,
+		Lo: lo, Hi: hi, Residual: math.NaN(),
+	}
+	return math.NaN(), diag, &BracketError{quantity, lo, hi, fLo, fHi}
+}
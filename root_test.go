@@ -0,0 +1,92 @@
+package halo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	tests := []struct {
+		f      func(float64) float64
+		lo, hi float64
+		root   float64
+	}{
+		{func(x float64) float64 { return x*x*x - 2*x - 5 }, 2, 3, 2.0945514815423265},
+		{func(x float64) float64 { return math.Cos(x) - x }, 0, 1, 0.7390851332151607},
+		{func(x float64) float64 { return math.Exp(x) - 1e5 }, -50, 50, 5 * math.Ln10},
+	}
+	for i, test := range tests {
+		x, diag, err := findRoot("x", test.f, test.lo, test.hi)
+		if err != nil {
+			t.Errorf("%d: %s", i, err)
+			continue
+		}
+		if math.Abs(x/test.root-1) > 2*rootRelTol {
+			t.Errorf("%d: root = %.16g, expected %.16g", i, x, test.root)
+		}
+		if diag.Lo > x || diag.Hi < x || diag.Residual != test.f(x) {
+			t.Errorf("%d: diagnostics %+v do not describe the root %.16g",
+				i, diag, x)
+		}
+	}
+}
+
+// TestFindEqualExpansion checks that roots outside of the initial bracket are
+// found after the expected number of expansions.
+func TestFindEqualExpansion(t *testing.T) {
+	cube := func(x float64) float64 { return x * x * x }
+	x, diag, err := findEqualConst("x", cube, 1000, 1e-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1e-3 * 2^14 is the first expansion to exceed 10.
+	if math.Abs(x/10-1) > 2*rootRelTol || diag.Expansions != 14 {
+		t.Errorf("findEqualConst: root = %.16g after %d expansions, "+
+			"expected 10 after 14", x, diag.Expansions)
+	}
+
+	three := func(float64) float64 { return 3 }
+	x, diag, err = findEqual("x", math.Log, three, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The upper edge of the bracket doubles from 2 to 32.
+	if root := math.Exp(3); math.Abs(x/root-1) > 2*rootRelTol ||
+		diag.Expansions != 4 {
+		t.Errorf("findEqual: root = %.16g after %d expansions, expected "+
+			"%.16g after 4", x, diag.Expansions, root)
+	}
+}
+
+func TestFindRootErrors(t *testing.T) {
+	square := func(x float64) float64 { return x*x + 1 }
+	_, diag, err := findEqualConst("x", square, 0, 1)
+	var bErr *BracketError
+	if !errors.As(err, &bErr) || diag.Expansions != rootSearchSteps {
+		t.Errorf("findEqualConst without a root: error %v after %d "+
+			"expansions, expected a *BracketError after %d", err,
+			diag.Expansions, rootSearchSteps)
+	}
+
+	// A step function changes sign without passing through zero.
+	step := func(x float64) float64 { return math.Copysign(1, x-1) }
+	_, _, err = findRoot("x", step, 0, 3)
+	var cErr *ConvergenceError
+	if !errors.As(err, &cErr) {
+		t.Errorf("findRoot on a discontinuity: error %v, expected a "+
+			"*ConvergenceError", err)
+	}
+
+	nan := func(x float64) float64 {
+		if x > 0.5 && x < 2.5 {
+			return math.NaN()
+		}
+		return x - 1
+	}
+	_, _, err = findRoot("x", nan, 0, 3)
+	if !errors.As(err, &cErr) {
+		t.Errorf("findRoot through NaN: error %v, expected a "+
+			"*ConvergenceError", err)
+	}
+}
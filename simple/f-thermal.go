@@ -140,8 +140,8 @@ func BetaBiasFunc(fTh RadialFuncType) RadialFuncType {
 
 func AlphaBiasFunc(fTh RadialFuncType) RadialFuncType {
 	return func(h *Halo, r float64) float64 {
-		return h.DPThermalDr(h.ppt, ElectronPressure, r) * r /
-			h.ThermalPressure(h.ppt, ElectronPressure, r) *
+		return h.DPThermalDr(h.pp, ElectronPressure, r) * r /
+			h.ThermalPressure(h.pp, ElectronPressure, r) *
 			cosmo.MpcMks
 	}
 }
@@ -46,7 +46,10 @@ func main() {
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		mass := math.Pow(10, massLog)
 		
-		h := simple.New(fTh, simPpt, cType, mass, 0.0)
+		h, err := simple.New(fTh, simPpt, cType, mass, 0.0)
+		if err != nil {
+			panic(err.Error())
+		}
 		mGasUnc := h.GasEnclosed(simple.Uncorrected, simPpt, h.C500.R)
 		mGasFC := h.GasEnclosed(simple.FlatCorrection, simPpt, h.C500.R)
 		fGasUnc := mGasUnc / h.C500.M
@@ -10,8 +10,8 @@ import (
 type haloInterface interface {
 	MassEnclosed(r float64) float64
 	OverdensityRadius(rho float64) float64
-	ThermalPressure(ppt PressureProfileType, pt PressurePopulationType, r float64) float64
-	DPThermalDr(ppt PressureProfileType, pt PressurePopulationType, r float64) float64
+	ThermalPressure(pp halo.PressureProfile, pt PressurePopulationType, r float64) float64
+	DPThermalDr(pp halo.PressureProfile, pt PressurePopulationType, r float64) float64
 	MinR() float64 
 }
 
@@ -29,18 +29,21 @@ type Halo struct {
     AlphaBias num.Func1D
     BetaBias num.Func1D
 
-	ppt PressureProfileType
+	pp halo.PressureProfile
 }
 
 // Typechecking
 var _ haloInterface = new(Halo)
 
-func New(fTh RadialFuncType, ppt PressureProfileType, cType halo.ConcentrationType, m200c, z float64) *Halo {
+func New(fTh RadialFuncType, pp halo.PressureProfile, cType halo.ConcentrationType, m200c, z float64) (*Halo, error) {
 	h := new(Halo)
 	h.Z = z
-	h.ppt = ppt
+	h.pp = pp
 
-	cFunc := halo.ConcentrationFunc(cType, z)
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		return nil, err
+	}
 
 	h.C200.R = haloRadius(m200c, 200 * cosmo.RhoCritical(z))
 	h.C200.M = m200c
@@ -56,5 +59,5 @@ func New(fTh RadialFuncType, ppt PressureProfileType, cType halo.ConcentrationTy
 	h.A200.M = haloMass(h.A200.R, 200 * cosmo.RhoAverage(z))
 	h.A200.C = h.Rs * h.A200.R
 
-	return h
+	return h, nil
 }
@@ -3,6 +3,7 @@ package simple
 import (
 	"math"
 
+	"github.com/phil-mansfield/halo"
 	"github.com/phil-mansfield/halo/cosmo"
 	"github.com/phil-mansfield/num"
 )
@@ -28,14 +29,14 @@ func acceleration(h *Halo, r float64) float64 {
 	return cosmo.GMks * (mEnclosed * cosmo.MSunMks) / (dist * dist)
 }
 
-func (h *Halo) RhoGas(xct XRayCorrectionType, ppt PressureProfileType, r float64) float64 {
+func (h *Halo) RhoGas(xct XRayCorrectionType, pp halo.PressureProfile, r float64) float64 {
 	var rho float64
 
 	switch xct {
 	case Uncorrected:
-		rho = -h.DPThermalDr(ppt, AllPressure, r) / acceleration(h, r)
+		rho = -h.DPThermalDr(pp, AllPressure, r) / acceleration(h, r)
 	case FlatCorrection:
-		rho = -h.DPThermalDr(ppt, AllPressure, r) / acceleration(h, r) * 
+		rho = -h.DPThermalDr(pp, AllPressure, r) / acceleration(h, r) * 
 			FlatCorrectionSize
 	default:
 		panic("Unrecognized XRayCorrectionType")
@@ -43,8 +44,8 @@ func (h *Halo) RhoGas(xct XRayCorrectionType, ppt PressureProfileType, r float64
 	return rho / cosmo.MSunMks * math.Pow(cosmo.MpcMks, 3.0)
 }
 
-func (h *Halo) GasEnclosed(xct XRayCorrectionType, ppt PressureProfileType, r float64) float64 {
-	rho := func(r float64) float64 { return h.RhoGas(xct, ppt, r) }
+func (h *Halo) GasEnclosed(xct XRayCorrectionType, pp halo.PressureProfile, r float64) float64 {
+	rho := func(r float64) float64 { return h.RhoGas(xct, pp, r) }
 	return num.Integral(rho, h.MinR(), 0.1, num.Log, num.Spherical)(r)
 }
 
@@ -55,16 +56,16 @@ func coolingLambda(temp float64) float64 {
 // Switch temp calculation from using an arbitrary profile to a thermal profile.
 // Get rid of pE factor.
 
-func createNumFunc(h *Halo, xct XRayCorrectionType, ppt PressureProfileType) num.Func1D {
+func createNumFunc(h *Halo, xct XRayCorrectionType, pp halo.PressureProfile) num.Func1D {
 	return func(r float64) float64 {
-		density := h.RhoGas(xct, ppt, r) * densityCosmoToMks
+		density := h.RhoGas(xct, pp, r) * densityCosmoToMks
 
 		var pE float64
 		switch xct {
 		case Uncorrected:
-			pE = h.ThermalPressure(ppt, ElectronPressure, r)
+			pE = h.ThermalPressure(pp, ElectronPressure, r)
 		case FlatCorrection:
-			pE = h.ThermalPressure(ppt, ElectronPressure, r) * FlatCorrectionSize
+			pE = h.ThermalPressure(pp, ElectronPressure, r) * FlatCorrectionSize
 		}
 
 		temp := pE * cosmo.ElectronMu * cosmo.MHyMks * kelvinToKeV /
@@ -73,16 +74,16 @@ func createNumFunc(h *Halo, xct XRayCorrectionType, ppt PressureProfileType) num
 	}
 }
 
-func createDenFunc(h *Halo, xct XRayCorrectionType, ppt PressureProfileType) num.Func1D {
+func createDenFunc(h *Halo, xct XRayCorrectionType, pp halo.PressureProfile) num.Func1D {
 	return func(r float64) float64 {
-		density := h.RhoGas(xct, ppt, r) * densityCosmoToMks
+		density := h.RhoGas(xct, pp, r) * densityCosmoToMks
 
 		var pE float64
 		switch xct {
 		case Uncorrected:
-			pE = h.ThermalPressure(ppt, ElectronPressure, r)
+			pE = h.ThermalPressure(pp, ElectronPressure, r)
 		case FlatCorrection:
-			pE = h.ThermalPressure(ppt, ElectronPressure, r) * FlatCorrectionSize
+			pE = h.ThermalPressure(pp, ElectronPressure, r) * FlatCorrectionSize
 		}
 
 		temp := pE * cosmo.ElectronMu * cosmo.MHyMks * kelvinToKeV /
@@ -91,14 +92,14 @@ func createDenFunc(h *Halo, xct XRayCorrectionType, ppt PressureProfileType) num
 	}
 }
 
-func (h *Halo) EWTemperature(xct XRayCorrectionType, ppt PressureProfileType, rMax float64) float64 {
+func (h *Halo) EWTemperature(xct XRayCorrectionType, pp halo.PressureProfile, rMax float64) float64 {
 	rMin := h.MinR()
 	scale := math.Log10(rMin) - math.Log10(rMin)
 
-	numFunc := createNumFunc(h, xct, ppt)
+	numFunc := createNumFunc(h, xct, pp)
 	numInt := num.Integral(numFunc, rMin, scale, num.Log, num.Spherical)
 
-	denFunc := createDenFunc(h, xct, ppt)
+	denFunc := createDenFunc(h, xct, pp)
 	denInt := num.Integral(denFunc, rMin, scale, num.Log, num.Spherical)
 
 	return numInt(rMax) / denInt(rMax)
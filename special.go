@@ -0,0 +1,138 @@
+package halo
+
+import (
+	"math"
+)
+
+const (
+	specialMaxIterations = 500
+	specialEps           = 1e-14
+	specialTiny          = 1e-300
+)
+
+func lgamma(x float64) float64 {
+	lg, _ := math.Lgamma(x)
+	return lg
+}
+
+// regGammaP computes the regularized lower incomplete gamma function,
+// P(a, x) = gamma(a, x) / Gamma(a), for a > 0 and x >= 0.
+func regGammaP(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+
+	prefactor := math.Exp(-x + a*math.Log(x) - lgamma(a))
+
+	if x < a+1 {
+		// Series representation.
+		ap := a
+		del := 1.0 / a
+		sum := del
+		for i := 0; i < specialMaxIterations; i++ {
+			ap++
+			del *= x / ap
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*specialEps {
+				break
+			}
+		}
+		return sum * prefactor
+	}
+
+	// Continued fraction representation of Q(a, x), evaluated with
+	// Lentz's method.
+	b := x + 1 - a
+	c := 1 / specialTiny
+	d := 1 / b
+	h := d
+	for i := 1; i <= specialMaxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = b + an/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < specialEps {
+			break
+		}
+	}
+	return 1 - prefactor*h
+}
+
+// betaCF evaluates the continued fraction for the incomplete beta function
+// using Lentz's method.
+func betaCF(a, b, x float64) float64 {
+	qab, qap, qam := a+b, a+1, a-1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < specialTiny {
+		d = specialTiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= specialMaxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < specialTiny {
+			d = specialTiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < specialTiny {
+			c = specialTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < specialEps {
+			break
+		}
+	}
+	return h
+}
+
+// regBetaI computes the regularized incomplete beta function, I_x(a, b), for
+// a, b > 0 and 0 <= x <= 1.
+func regBetaI(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	} else if x >= 1 {
+		return 1
+	}
+
+	front := math.Exp(lgamma(a+b) - lgamma(a) - lgamma(b) +
+		a*math.Log(x) + b*math.Log(1-x))
+
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaFunc computes the complete beta function, B(a, b).
+func betaFunc(a, b float64) float64 {
+	return math.Exp(lgamma(a) + lgamma(b) - lgamma(a+b))
+}
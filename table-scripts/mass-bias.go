@@ -4,11 +4,14 @@ package main
 // masses at different redshifts and different std-dev bins.
 
 import (
+	"context"
 	"os"
 	"path"
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -19,12 +22,12 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	fThP = halo.FThermalFunc(simFth, halo.PlusSigmaCurve)
-	fThM = halo.FThermalFunc(simFth, halo.MinusSigmaCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	fThP = fThermalFunc(simFth, halo.PlusSigmaCurve)
+	fThM = fThermalFunc(simFth, halo.MinusSigmaCurve)
 
-	cFunc0 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
-	cFunc2 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.2)
+	cFunc0 = concentrationFunc(halo.Bhattacharya2013, 0.0)
+	cFunc2 = concentrationFunc(halo.Bhattacharya2013, 0.2)
 
 	colNames = []string {
 		"m500c-bias",
@@ -48,25 +51,67 @@ func main() {
 	minMassLog, maxMassLog := math.Log10(1e13), math.Log10(1e15)
 	logWidth := (maxMassLog - minMassLog) / steps
 
+	masses := []float64{}
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
-		mass := math.Pow(10, massLog)
-
-		h0 := halo.New(fTh, simPpt, cFunc0, halo.Biased, mass, 0.0)
-		h0p := halo.New(fThP, simPpt, cFunc0, halo.Biased, mass, 0.0)
-		h0m := halo.New(fThM, simPpt, cFunc0, halo.Biased, mass, 0.0)
-
-		h2 := halo.New(fTh, simPpt, cFunc2, halo.Biased, mass, 0.2)
-		h2p := halo.New(fThP, simPpt, cFunc2, halo.Biased, mass, 0.2)
-		h2m := halo.New(fThM, simPpt, cFunc2, halo.Biased, mass, 0.2)
-
-		outTable.AddRow(mass,
-			h0.C500.M / h0.M500cBias,
-			h0p.C500.M / h0p.M500cBias,
-			h0m.C500.M / h0m.M500cBias,
-			h2.C500.M / h2.M500cBias,
-			h2p.C500.M / h2p.M500cBias,
-			h2m.C500.M / h2m.M500cBias)
+		masses = append(masses, math.Pow(10, massLog))
+	}
+
+	// One spec per column of the table, per mass.
+	cols := []struct {
+		fTh   halo.FThermalModel
+		cFunc num.Func1D
+		z     float64
+	}{
+		{fTh, cFunc0, 0.0}, {fThP, cFunc0, 0.0}, {fThM, cFunc0, 0.0},
+		{fTh, cFunc2, 0.2}, {fThP, cFunc2, 0.2}, {fThM, cFunc2, 0.2},
+	}
+
+	specs := []halo.HaloSpec{}
+	for _, mass := range masses {
+		for _, col := range cols {
+			specs = append(specs, halo.HaloSpec{
+				Cosmology: cosmo.Fiducial,
+				FThermal: col.fTh, Pressure: simPpt,
+				Concentration: col.cFunc, Bias: halo.Biased,
+				M500c: mass, Z: col.z,
+			})
+		}
+	}
+
+	results, err := halo.EvalBatch(context.Background(), specs, 0,
+		func(h *halo.Halo) ([]float64, error) {
+			return []float64{h.C500.M / h.M500cBias}, nil
+		})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for i, mass := range masses {
+		row := []float64{mass}
+		for _, res := range results[i*len(cols) : (i+1)*len(cols)] {
+			if res.Err != nil {
+				panic(res.Err.Error())
+			}
+			row = append(row, res.Values[0])
+		}
+		outTable.AddRow(row...)
 	}
 
 	outTable.Write(table.KeepHeader, path.Join(outDir, "mass-bias.table"))
 }
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
@@ -9,6 +9,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -19,8 +21,8 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	cFunc = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	cFunc = concentrationFunc(halo.Bhattacharya2013, 0.0)
 
 	colNames = []string {
 		"m500true",
@@ -45,7 +47,7 @@ func main() {
 
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		mass := math.Pow(10, massLog)
-		h := halo.New(fTh, simPpt, cFunc, halo.Biased, mass, 0.0)
+		h := newHalo(fTh, simPpt, cFunc, halo.Biased, mass, 0.0)
 
 		outTable.AddRow(mass,
 			h.C500.M / h.M500cBias,
@@ -57,3 +59,28 @@ func main() {
 
 	outTable.Write(table.KeepHeader, path.Join(outDir, "mass-false-bias.table"))
 }
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -23,11 +25,11 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	fThP = halo.FThermalFunc(simFth, halo.PlusSigmaCurve)
-	fThM = halo.FThermalFunc(simFth, halo.MinusSigmaCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	fThP = fThermalFunc(simFth, halo.PlusSigmaCurve)
+	fThM = fThermalFunc(simFth, halo.MinusSigmaCurve)
 
-	cFunc0 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
+	cFunc0 = concentrationFunc(halo.Bhattacharya2013, 0.0)
 
 	colNames = []string {
 		"m500",
@@ -62,7 +64,7 @@ func main() {
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		mass := math.Pow(10, massLog)
 		
-		h := halo.New(fTh, simPpt, cFunc0, halo.Corrected, mass, 0.0)
+		h := newHalo(fTh, simPpt, cFunc0, halo.Corrected, mass, 0.0)
 
 		fGasCorrected := fGas(h, halo.Corrected, halo.EffectivePressure)
 		fGasNaive := fGas(h, halo.Corrected, halo.NaiveThermalPressure)
@@ -72,3 +74,28 @@ func main() {
 
 	outTable.Write(table.KeepHeader, path.Join(outDir, "mass-fgas.table"))
 }
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
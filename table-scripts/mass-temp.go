@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -19,12 +21,12 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	fThP = halo.FThermalFunc(simFth, halo.PlusSigmaCurve)
-	fThM = halo.FThermalFunc(simFth, halo.MinusSigmaCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	fThP = fThermalFunc(simFth, halo.PlusSigmaCurve)
+	fThM = fThermalFunc(simFth, halo.MinusSigmaCurve)
 
-	cFunc0 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
-	cFunc5 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.5)
+	cFunc0 = concentrationFunc(halo.Bhattacharya2013, 0.0)
+	cFunc5 = concentrationFunc(halo.Bhattacharya2013, 0.5)
 
 	colNames = []string {
 		"m500c",
@@ -50,13 +52,13 @@ func main() {
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		mass := math.Pow(10, massLog)
 
-		h0 := halo.New(fTh, simPpt, cFunc0, halo.Biased, mass, 0.0)
-		h0p := halo.New(fThP, simPpt, cFunc0, halo.Biased, mass, 0.0)
-		h0m := halo.New(fThM, simPpt, cFunc0, halo.Biased, mass, 0.0)
+		h0 := newHalo(fTh, simPpt, cFunc0, halo.Biased, mass, 0.0)
+		h0p := newHalo(fThP, simPpt, cFunc0, halo.Biased, mass, 0.0)
+		h0m := newHalo(fThM, simPpt, cFunc0, halo.Biased, mass, 0.0)
 
-		h5 := halo.New(fTh, simPpt, cFunc5, halo.Biased, mass, 0.5)
-		h5p := halo.New(fThP, simPpt, cFunc5, halo.Biased, mass, 0.5)
-		h5m := halo.New(fThM, simPpt, cFunc5, halo.Biased, mass, 0.5)
+		h5 := newHalo(fTh, simPpt, cFunc5, halo.Biased, mass, 0.5)
+		h5p := newHalo(fThP, simPpt, cFunc5, halo.Biased, mass, 0.5)
+		h5m := newHalo(fThM, simPpt, cFunc5, halo.Biased, mass, 0.5)
 
 		outTable.AddRow(mass,
 			h0.EWTemperature(tempBt, valPpt, h5p.C500.R),
@@ -69,3 +71,28 @@ func main() {
 
 	outTable.Write(table.KeepHeader, path.Join(outDir, "mass-temp.table"))
 }
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -17,11 +19,11 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	fThP = halo.FThermalFunc(simFth, halo.PlusSigmaCurve)
-	fThM = halo.FThermalFunc(simFth, halo.MinusSigmaCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	fThP = fThermalFunc(simFth, halo.PlusSigmaCurve)
+	fThM = fThermalFunc(simFth, halo.MinusSigmaCurve)
 
-	cFunc0 = halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
+	cFunc0 = concentrationFunc(halo.Bhattacharya2013, 0.0)
 
 	colNames = []string {
 		"m500",
@@ -45,7 +47,7 @@ func main() {
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		mass := math.Pow(10, massLog)
 		
-		h := halo.New(fTh, simPpt, cFunc0, halo.Corrected, mass, 0.0)
+		h := newHalo(fTh, simPpt, cFunc0, halo.Corrected, mass, 0.0)
 
 		mGas500Corrected := h.GasEnclosed(halo.Biased,
 			halo.EffectivePressure, valPpt, h.C500.R)
@@ -60,3 +62,28 @@ func main() {
 
 	outTable.Write(table.KeepHeader, path.Join(outDir, "plot-type-comp.table"))
 }
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
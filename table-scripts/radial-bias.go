@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -17,7 +19,7 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
 
 	alphaNames = []string {
 		"r/r500",
@@ -67,13 +69,13 @@ func main() {
 	fthTable := table.NewOutTable(fthNames...)
 	biasTable := table.NewOutTable(biasNames...)
 
-	cFunc0 := halo.ConcentrationFunc(halo.Bhattacharya2013, 0)
-	cFunc5 := halo.ConcentrationFunc(halo.Bhattacharya2013, 0.5)
+	cFunc0 := concentrationFunc(halo.Bhattacharya2013, 0)
+	cFunc5 := concentrationFunc(halo.Bhattacharya2013, 0.5)
 
-	h014 := halo.New(fTh, simPpt, cFunc0, halo.Corrected, 1e14, 0)
-	h015 := halo.New(fTh, simPpt, cFunc0, halo.Corrected, 5e14, 0)
-	h214 := halo.New(fTh, simPpt, cFunc5, halo.Corrected, 1e14, 0.2)
-	h215 := halo.New(fTh, simPpt, cFunc5, halo.Corrected, 5e14, 0.2)
+	h014 := newHalo(fTh, simPpt, cFunc0, halo.Corrected, 1e14, 0)
+	h015 := newHalo(fTh, simPpt, cFunc0, halo.Corrected, 5e14, 0)
+	h214 := newHalo(fTh, simPpt, cFunc5, halo.Corrected, 1e14, 0.2)
+	h215 := newHalo(fTh, simPpt, cFunc5, halo.Corrected, 5e14, 0.2)
 
 	minFracLog, maxFracLog := math.Log10(0.01), math.Log10(10)
 	logWidth := (maxFracLog - minFracLog) / steps
@@ -113,4 +115,29 @@ func main() {
 	betaTable.Write(table.KeepHeader, path.Join(outDir, "radial-beta.table"))
 	fthTable.Write(table.KeepHeader, path.Join(outDir, "radial-fth.table"))
 	biasTable.Write(table.KeepHeader, path.Join(outDir, "radial-bias.table"))
-}
\ No newline at end of file
+}
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
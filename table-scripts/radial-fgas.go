@@ -7,6 +7,7 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
 	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
@@ -22,7 +23,7 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
 
 	fgasNames = []string {
 		"r/r500c",
@@ -54,13 +55,13 @@ func main() {
 	fgasTable := table.NewOutTable(fgasNames...)
 	normTable := table.NewOutTable(normNames...)
 
-	cFunc0 := halo.ConcentrationFunc(halo.Bhattacharya2013, 0.0)
-	cFunc5 := halo.ConcentrationFunc(halo.Bhattacharya2013, 0.5)
+	cFunc0 := concentrationFunc(halo.Bhattacharya2013, 0.0)
+	cFunc5 := concentrationFunc(halo.Bhattacharya2013, 0.5)
 
-	h014 := halo.New(fTh, simPpt, cFunc0, halo.Corrected, 1e14, 0.0)
-	h015 := halo.New(fTh, simPpt, cFunc0, halo.Corrected, 5e14, 0.0)
-	h514 := halo.New(fTh, simPpt, cFunc5, halo.Corrected, 1e14, 0.5)
-	h515 := halo.New(fTh, simPpt, cFunc5, halo.Corrected, 5e14, 0.5)
+	h014 := newHalo(fTh, simPpt, cFunc0, halo.Corrected, 1e14, 0.0)
+	h015 := newHalo(fTh, simPpt, cFunc0, halo.Corrected, 5e14, 0.0)
+	h514 := newHalo(fTh, simPpt, cFunc5, halo.Corrected, 1e14, 0.5)
+	h515 := newHalo(fTh, simPpt, cFunc5, halo.Corrected, 5e14, 0.5)
 
 	minFracLog, maxFracLog := math.Log10(0.01), math.Log10(10)
 	logWidth := (maxFracLog - minFracLog) / steps
@@ -92,4 +93,29 @@ func main() {
 		path.Join(outDir, "radial-density-frac.table"))
 	normTable.Write(table.KeepHeader,
 		path.Join(outDir, "radial-density-frac-norm.table"))
-}
\ No newline at end of file
+}
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
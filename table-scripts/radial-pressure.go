@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -15,8 +17,8 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(halo.Battaglia2013, halo.MeanCurve)
-	cFunc = halo.ConcentrationFunc(halo.Bhattacharya2013, z)
+	fTh = fThermalFunc(halo.Battaglia2013, halo.MeanCurve)
+	cFunc = concentrationFunc(halo.Bhattacharya2013, z)
 
 	colNames = []string {
 		"r/r500c",
@@ -35,13 +37,13 @@ func main() {
 	outFile := path.Join(outDir, "radial-pressure.table")
 	t := table.NewOutTable(colNames...)
 
-	h014 := halo.New(fTh, halo.BattagliaAGN2012,
+	h014 := newHalo(fTh, halo.BattagliaAGN2012,
 		cFunc, halo.Corrected, 1e14, 0)
-	h015 := halo.New(fTh, halo.BattagliaAGN2012,
+	h015 := newHalo(fTh, halo.BattagliaAGN2012,
 		cFunc, halo.Corrected, 5e14, 0)
-	h214 := halo.New(fTh, halo.BattagliaAGN2012,
+	h214 := newHalo(fTh, halo.BattagliaAGN2012,
 		cFunc, halo.Corrected, 1e14, 0.2)
-	h215 := halo.New(fTh, halo.BattagliaAGN2012,
+	h215 := newHalo(fTh, halo.BattagliaAGN2012,
 		cFunc, halo.Corrected, 5e14, 0.2)
 
 	minFracLog, maxFracLog := math.Log10(0.01), math.Log10(10)
@@ -66,4 +68,29 @@ func main() {
 	}
 
 	t.Write(table.KeepHeader, outFile)
-}
\ No newline at end of file
+}
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 
 	"bitbucket.org/phil-mansfield/halo"
+	"bitbucket.org/phil-mansfield/halo/num"
+	"bitbucket.org/phil-mansfield/halo/cosmo"
 	"bitbucket.org/phil-mansfield/table"
 )
 
@@ -21,8 +23,8 @@ const (
 )
 
 var (
-	fTh = halo.FThermalFunc(simFth, halo.MeanCurve)
-	cFunc = halo.ConcentrationFunc(halo.Bhattacharya2013, z)
+	fTh = fThermalFunc(simFth, halo.MeanCurve)
+	cFunc = concentrationFunc(halo.Bhattacharya2013, z)
 
 	tempColNames = []string {
 		"m500c-b",
@@ -74,7 +76,7 @@ func main() {
 	for massLog := minMassLog; massLog <= maxMassLog; massLog += logWidth {
 		bMass := math.Pow(10, massLog)
 
-		h := halo.New(fTh, ppt, cFunc, halo.Biased, bMass, z)
+		h := newHalo(fTh, ppt, cFunc, halo.Biased, bMass, z)
 
 
 		bTemp := h.EWTemperature(halo.Biased, bpbt, ppt, h.R500cBias)
@@ -94,3 +96,28 @@ func main() {
 	yTable.Write(table.KeepHeader, path.Join(outDir, "mass-y.table"))
 	fGasTable.Write(table.KeepHeader, path.Join(outDir, "mass-fgas.table"))
 }
+
+func newHalo(fTh halo.FThermalModel, ppt halo.PressureProfileType, cFunc num.Func1D, bt halo.BiasType, m500c, z float64) *halo.Halo {
+	h, err := halo.New(cosmo.Fiducial, fTh, ppt,
+		halo.MassProfile{Type: halo.NFW}, cFunc, bt, m500c, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+func fThermalFunc(ftt halo.FThermalType, ftct halo.FThermalCurveType) halo.FThermalModel {
+	fTh, err := halo.FThermalFunc(ftt, ftct)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fTh
+}
+
+func concentrationFunc(cType halo.ConcentrationType, z float64) num.Func1D {
+	cFunc, err := halo.ConcentrationFunc(cosmo.Fiducial, cType, z)
+	if err != nil {
+		panic(err.Error())
+	}
+	return cFunc
+}
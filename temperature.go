@@ -20,22 +20,22 @@ func coolingLambda(temp float64) float64 {
 // Switch temp calculation from using an arbitrary profile to a thermal profile.
 // Get rid of pE factor.
 
-func createNumFunc(h *Halo, pbt PressureBiasType, ppt PressureProfileType, bt BiasType) num.Func1D {
+func createNumFunc(h *Halo, pbt PressureBiasType, pp PressureProfile, bt BiasType) num.Func1D {
 	return func(r float64) float64 {
-		density := h.RhoGas(bt, pbt, ppt, r) * densityCosmoToMks
-		pE := h.Pressure(pbt, ppt, ElectronPressure, r)
-		temp := pE * cosmo.ElectronMu * cosmo.MHyMks * kelvinToKeV /
-			(cosmo.KBMks * density)
+		density := h.RhoGas(bt, pbt, pp, r) * densityCosmoToMks
+		pE := h.Pressure(pbt, pp, ElectronPressure, r)
+		temp := pE * cosmo.MHyMks * kelvinToKeV /
+			(h.cosmology.ElectronMu() * cosmo.KBMks * density)
 		return pE * density * density * temp * coolingLambda(temp)
 	}
 }
 
-func createDenFunc(h *Halo, pbt PressureBiasType, ppt PressureProfileType, bt BiasType) num.Func1D {
+func createDenFunc(h *Halo, pbt PressureBiasType, pp PressureProfile, bt BiasType) num.Func1D {
 	return func(r float64) float64 {
-		density := h.RhoGas(bt, pbt, ppt, r) * densityCosmoToMks
-		pE := h.Pressure(pbt, ppt, ElectronPressure, r)
-		temp := pE * cosmo.ElectronMu * cosmo.MHyMks * kelvinToKeV /
-			(cosmo.KBMks * density)
+		density := h.RhoGas(bt, pbt, pp, r) * densityCosmoToMks
+		pE := h.Pressure(pbt, pp, ElectronPressure, r)
+		temp := pE * cosmo.MHyMks * kelvinToKeV /
+			(h.cosmology.ElectronMu() * cosmo.KBMks * density)
 		return pE * density * density * coolingLambda(temp)
 	}
 }
@@ -48,18 +48,18 @@ func createDenFunc(h *Halo, pbt PressureBiasType, ppt PressureProfileType, bt Bi
 //
 // Here, Lambda is a cooling coefficient taken to be proportional to 
 // sqrt(T) and the temperature is calculated from the pressure profile which
-// is specified by pbt and ppt. bt is necceary because we're using PV = nRT
+// is specified by pbt and pp. bt is necceary because we're using PV = nRT
 // and thus need ot figure out the 
 //
 // Return value is in keV.
-func (h *Halo) EWTemperature(bt BiasType, pbt PressureBiasType, ppt PressureProfileType, rMax float64) float64 {
+func (h *Halo) EWTemperature(bt BiasType, pbt PressureBiasType, pp PressureProfile, rMax float64) float64 {
 	rMin := h.MinR()
 	scale := math.Log10(rMax) - math.Log10(rMin)
 
-	numFunc := createNumFunc(h, pbt, ppt, bt)
+	numFunc := createNumFunc(h, pbt, pp, bt)
 	numInt := num.Integral(numFunc, rMin, scale, num.Log, num.Spherical)
 
-	denFunc := createDenFunc(h, pbt, ppt, bt)
+	denFunc := createDenFunc(h, pbt, pp, bt)
 	denInt := num.Integral(denFunc, rMin, scale, num.Log, num.Spherical)
 
 	return numInt(rMax) / denInt(rMax)